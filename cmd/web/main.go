@@ -5,8 +5,12 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 
+	"github.com/dilithaw123/broccoli-backend/internal/bus"
 	"github.com/dilithaw123/broccoli-backend/internal/group"
+	"github.com/dilithaw123/broccoli-backend/internal/mail"
+	"github.com/dilithaw123/broccoli-backend/internal/oidc"
 	"github.com/dilithaw123/broccoli-backend/internal/session"
 	"github.com/dilithaw123/broccoli-backend/internal/user"
 	"github.com/dilithaw123/broccoli-backend/internal/web"
@@ -22,9 +26,9 @@ func main() {
 		os.Exit(1)
 	}
 
-	apikey, ok := os.LookupEnv("API_KEY")
-	if !ok {
-		logger.Error("API_KEY environment variable is required")
+	keys, err := loadKeyring(secret)
+	if err != nil {
+		logger.Error("Failed to load signing keys", "error", err)
 		os.Exit(1)
 	}
 
@@ -50,20 +54,133 @@ func main() {
 	logger.Info("Connected to database")
 	defer pool.Close()
 	userService := user.NewPgUserRepo(pool)
+	refreshTokenService := user.NewPgRefreshTokenRepo(pool)
+	loginTokenService := user.NewPgLoginTokenRepo(pool)
+	accessTokenService := user.NewPgAccessTokenRepo(pool)
 	groupService := group.NewPgGroupRepo(pool)
+	inviteService := group.NewPgInviteRepo(pool)
+	scheduleService := group.NewPgScheduleRepo(pool)
+	skipDateService := group.NewPgSkipDateRepo(pool)
 	sessionService := session.NewPgSessionRepo(pool)
-	server := web.NewServer(
-		pool,
+	opts := []web.BuilderOpts{
 		web.WithDB(pool),
 		web.WithLogger(logger),
 		web.WithUserService(userService),
+		web.WithRefreshTokenService(refreshTokenService),
+		web.WithLoginTokenService(loginTokenService),
+		web.WithPersonalAccessTokenService(accessTokenService),
+		web.WithMailer(newMailer(logger)),
+		web.WithMagicLinkURL(getenvDefault("MAGIC_LINK_URL", "https://broccoli.buzz/login/magic")),
 		web.WithGroupService(groupService),
+		web.WithInviteService(inviteService),
+		web.WithInviteURL(getenvDefault("INVITE_URL", "https://broccoli.buzz/invite")),
+		web.WithScheduleService(scheduleService),
+		web.WithSkipDateService(skipDateService),
 		web.WithSessionService(sessionService),
+		web.WithBus(bus.NewPgBus(pool, logger)),
 		web.WithMux(http.NewServeMux()),
-		web.WithSecretKey(secret),
-		web.WithApiKey(apikey),
-	)
+		web.WithKeyring(keys),
+		// Leaving API_KEY unset disables the legacy /login endpoint
+		web.WithApiKey(os.Getenv("API_KEY")),
+		web.WithPostLoginURL(getenvDefault("POST_LOGIN_URL", "https://broccoli.buzz/")),
+		web.WithCookieDomain(os.Getenv("COOKIE_DOMAIN")),
+		web.WithSecureCookies(os.Getenv("INSECURE_COOKIES") != "true"),
+		web.WithTokensInBody(os.Getenv("TOKENS_IN_BODY") == "true"),
+	}
+	for _, provider := range oidcProviders() {
+		opts = append(opts, web.WithOIDCProvider(provider))
+	}
+	server := web.NewServer(pool, opts...)
 	if err := server.Start(":5050"); err != nil {
 		slog.Error("Failed to start server", "error", err)
 	}
 }
+
+// SECRET_KEY signs tokens unless JWT_PRIVATE_KEY_FILE points to an Ed25519 or
+// RSA key. PREVIOUS_SECRET_KEYS and JWT_PUBLIC_KEY_FILES are comma separated
+// retired keys that are still accepted while their tokens expire.
+func loadKeyring(secret string) (*web.Keyring, error) {
+	signing := web.NewHMACKey(secret)
+	var verifyOnly []web.SigningKey
+	if path := os.Getenv("JWT_PRIVATE_KEY_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		verifyOnly = append(verifyOnly, signing)
+		if signing, err = web.ParsePrivateKeyPEM(data); err != nil {
+			return nil, err
+		}
+	}
+	for _, old := range splitList(os.Getenv("PREVIOUS_SECRET_KEYS")) {
+		verifyOnly = append(verifyOnly, web.NewHMACKey(old))
+	}
+	for _, path := range splitList(os.Getenv("JWT_PUBLIC_KEY_FILES")) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := web.ParsePublicKeyPEM(data)
+		if err != nil {
+			return nil, err
+		}
+		verifyOnly = append(verifyOnly, key)
+	}
+	return web.NewKeyring(signing, verifyOnly...)
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Mail goes through SMTP_HOST when it is set, to MAIL_FILE when that is set
+// and to the log otherwise.
+func newMailer(logger *slog.Logger) mail.Mailer {
+	from := getenvDefault("MAIL_FROM", "noreply@broccoli.buzz")
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		return mail.NewSMTPMailer(
+			host,
+			getenvDefault("SMTP_PORT", "587"),
+			from,
+			os.Getenv("SMTP_USERNAME"),
+			os.Getenv("SMTP_PASSWORD"),
+		)
+	}
+	if path := os.Getenv("MAIL_FILE"); path != "" {
+		return mail.NewFileMailer(path, from)
+	}
+	return mail.NewLogMailer(logger)
+}
+
+func getenvDefault(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+// OIDC_PROVIDERS lists provider names. Each one is configured with
+// OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID, OIDC_<NAME>_CLIENT_SECRET and the
+// optional OIDC_<NAME>_ALLOWED_DOMAINS.
+func oidcProviders() []*oidc.Provider {
+	base := strings.TrimSuffix(os.Getenv("OIDC_REDIRECT_BASE_URL"), "/")
+	var providers []*oidc.Provider
+	for _, name := range splitList(os.Getenv("OIDC_PROVIDERS")) {
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		providers = append(providers, oidc.NewProvider(oidc.Config{
+			Name:           name,
+			IssuerURL:      os.Getenv(prefix + "ISSUER"),
+			ClientID:       os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret:   os.Getenv(prefix + "CLIENT_SECRET"),
+			RedirectURL:    base + "/login/oidc/" + name + "/callback",
+			AllowedDomains: splitList(os.Getenv(prefix + "ALLOWED_DOMAINS")),
+		}, nil))
+	}
+	return providers
+}
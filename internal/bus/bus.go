@@ -0,0 +1,28 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+var ErrPayloadTooLarge = errors.New("event payload is too large for the bus")
+
+// Something that happened in a session room that every instance of the
+// server needs to hear about
+type Event struct {
+	SessionID uint64          `json:"session_id"`
+	Kind      string          `json:"kind"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	// The instance that published the event
+	Origin string `json:"origin"`
+}
+
+// A Bus delivers every published event to every subscriber, including ones in
+// the publishing process. Delivery is best effort: events published while a
+// subscriber is reconnecting are lost.
+type Bus interface {
+	Publish(ctx context.Context, e Event) error
+	// The channel is closed once ctx is done
+	Subscribe(ctx context.Context) (<-chan Event, error)
+}
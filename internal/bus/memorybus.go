@@ -0,0 +1,48 @@
+package bus
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+const subscriberBuffer = 256
+
+// MemoryBus connects subscribers within a single process, for tests and for
+// running one instance
+type MemoryBus struct {
+	mu   sync.Mutex
+	subs []chan Event
+}
+
+func NewMemoryBus() *MemoryBus {
+	return &MemoryBus{}
+}
+
+func (b *MemoryBus) Publish(ctx context.Context, e Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, sub := range b.subs {
+		select {
+		case sub <- e:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBus) Subscribe(ctx context.Context) (<-chan Event, error) {
+	sub := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	b.subs = append(b.subs, sub)
+	b.mu.Unlock()
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.subs = slices.DeleteFunc(b.subs, func(c chan Event) bool { return c == sub })
+		close(sub)
+	}()
+	return sub, nil
+}
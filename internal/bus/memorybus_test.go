@@ -0,0 +1,25 @@
+package bus
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryBusFansOut(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := NewMemoryBus()
+	a, _ := b.Subscribe(ctx)
+	c, _ := b.Subscribe(ctx)
+	if err := b.Publish(ctx, Event{SessionID: 7, Kind: "message", Origin: "x"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range []<-chan Event{a, c} {
+		if e := <-sub; e.SessionID != 7 || e.Kind != "message" {
+			t.Errorf("Unexpected event %+v", e)
+		}
+	}
+	cancel()
+	if _, ok := <-a; ok {
+		t.Error("Subscription should close with its context")
+	}
+}
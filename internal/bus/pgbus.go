@@ -0,0 +1,88 @@
+package bus
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const (
+	pgChannel = "broccoli_rooms"
+	// Postgres rejects NOTIFY payloads of 8000 bytes or more
+	maxPgPayload   = 7999
+	reconnectDelay = time.Second
+)
+
+// PgBus fans events out to every instance through Postgres LISTEN/NOTIFY
+type PgBus struct {
+	pool   *pgxpool.Pool
+	logger *slog.Logger
+}
+
+func NewPgBus(pool *pgxpool.Pool, logger *slog.Logger) *PgBus {
+	return &PgBus{pool: pool, logger: logger}
+}
+
+func (b *PgBus) Publish(ctx context.Context, e Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if len(data) > maxPgPayload {
+		return ErrPayloadTooLarge
+	}
+	_, err = b.pool.Exec(ctx, "SELECT pg_notify($1, $2)", pgChannel, string(data))
+	return err
+}
+
+// Listens on a connection of its own, reconnecting until ctx is done
+func (b *PgBus) Subscribe(ctx context.Context) (<-chan Event, error) {
+	events := make(chan Event, subscriberBuffer)
+	go func() {
+		defer close(events)
+		for ctx.Err() == nil {
+			err := b.listen(ctx, events)
+			if ctx.Err() != nil {
+				return
+			}
+			b.logger.Error("Lost bus connection", "error", err)
+			select {
+			case <-time.After(reconnectDelay):
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return events, nil
+}
+
+func (b *PgBus) listen(ctx context.Context, events chan<- Event) error {
+	pooled, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// A listening connection can't go back to the pool, so it is ours to close
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+	if _, err := conn.Exec(ctx, "LISTEN "+pgChannel); err != nil {
+		return err
+	}
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(n.Payload), &e); err != nil {
+			b.logger.Error("Failed to decode bus event", "error", err)
+			continue
+		}
+		select {
+		case events <- e:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
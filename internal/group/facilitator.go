@@ -0,0 +1,48 @@
+package group
+
+import (
+	"errors"
+	"slices"
+)
+
+// How a group's sessions get their facilitator
+type FacilitatorPolicy string
+
+const (
+	// Members take turns in member order
+	FacilitatorRoundRobin FacilitatorPolicy = "round_robin"
+	FacilitatorRandom     FacilitatorPolicy = "random"
+	// Always the group's chosen facilitator while they are a member
+	FacilitatorFixed FacilitatorPolicy = "fixed"
+)
+
+var (
+	ErrInvalidFacilitatorPolicy = errors.New("facilitator_policy must be round_robin, random or fixed")
+	ErrFacilitatorRequired      = errors.New("the fixed policy needs a facilitator_email")
+)
+
+func (p FacilitatorPolicy) Valid() bool {
+	switch p {
+	case FacilitatorRoundRobin, FacilitatorRandom, FacilitatorFixed:
+		return true
+	}
+	return false
+}
+
+// Picks the facilitator for a new session from the candidates, in member
+// order. previous is the last session's facilitator and seed the new session's
+// random seed. A fixed facilitator who left the group falls back to round
+// robin. Returns "" when there are no candidates.
+func (p FacilitatorPolicy) Pick(candidates []string, previous, fixed string, seed uint16) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	if p == FacilitatorFixed && slices.Contains(candidates, fixed) {
+		return fixed
+	}
+	if p == FacilitatorRandom {
+		return candidates[int(seed)%len(candidates)]
+	}
+	// Index is -1 when previous left or there was none, which starts at the top
+	return candidates[(slices.Index(candidates, previous)+1)%len(candidates)]
+}
@@ -0,0 +1,32 @@
+package group
+
+import "testing"
+
+func TestFacilitatorPick(t *testing.T) {
+	members := []string{"a@example.com", "b@example.com", "c@example.com"}
+	tests := []struct {
+		policy   FacilitatorPolicy
+		previous string
+		fixed    string
+		seed     uint16
+		want     string
+	}{
+		{FacilitatorRoundRobin, "", "", 0, "a@example.com"},
+		{FacilitatorRoundRobin, "a@example.com", "", 0, "b@example.com"},
+		{FacilitatorRoundRobin, "c@example.com", "", 0, "a@example.com"},
+		{FacilitatorRoundRobin, "gone@example.com", "", 0, "a@example.com"},
+		{FacilitatorRandom, "", "", 4, "b@example.com"},
+		{FacilitatorFixed, "a@example.com", "c@example.com", 0, "c@example.com"},
+		{FacilitatorFixed, "a@example.com", "gone@example.com", 0, "b@example.com"},
+	}
+	for _, tt := range tests {
+		got := tt.policy.Pick(members, tt.previous, tt.fixed, tt.seed)
+		if got != tt.want {
+			t.Errorf("%s.Pick(previous=%q, fixed=%q) = %q, want %q",
+				tt.policy, tt.previous, tt.fixed, got, tt.want)
+		}
+	}
+	if got := FacilitatorRoundRobin.Pick(nil, "", "", 0); got != "" {
+		t.Errorf("Expected no facilitator without candidates, got %q", got)
+	}
+}
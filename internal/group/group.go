@@ -1,10 +1,38 @@
 package group
 
+import "github.com/dilithaw123/broccoli-backend/internal/types"
+
+// FacilitatorEmail is only used by the fixed facilitator policy
 type Group struct {
-	ID            uint64   `json:"id"             db:"id"`
-	Name          string   `json:"name"           db:"name"`
-	AllowedEmails []string `json:"allowed_emails" db:"allowed_emails"`
-	Timezone      string   `json:"timezone"       db:"timezone"`
+	ID                uint64            `json:"id"                 db:"id"`
+	Name              string            `json:"name"               db:"name"`
+	AllowedEmails     []string          `json:"allowed_emails"     db:"allowed_emails"`
+	Timezone          string            `json:"timezone"           db:"timezone"`
+	Description       string            `json:"description"        db:"description"`
+	AvatarURL         string            `json:"avatar_url"         db:"avatar_url"`
+	FacilitatorPolicy FacilitatorPolicy `json:"facilitator_policy" db:"facilitator_policy"`
+	FacilitatorEmail  *string           `json:"facilitator_email"  db:"facilitator_email"`
+	SpeakerSeconds    int               `json:"speaker_seconds"    db:"speaker_seconds"`
+}
+
+// A GroupUpdate changes the settings that are not nil. An empty
+// FacilitatorEmail clears it.
+type GroupUpdate struct {
+	Name              *string            `json:"name"`
+	Timezone          *string            `json:"timezone"`
+	Description       *string            `json:"description"`
+	AvatarURL         *string            `json:"avatar_url"`
+	FacilitatorPolicy *FacilitatorPolicy `json:"facilitator_policy"`
+	FacilitatorEmail  *string            `json:"facilitator_email"`
+	SpeakerSeconds    *int               `json:"speaker_seconds"`
+}
+
+type Member struct {
+	GroupID  uint64           `json:"group_id"  db:"group_id"`
+	Email    string           `json:"email"     db:"email"`
+	Role     Role             `json:"role"      db:"role"`
+	JoinDate types.CustomTime `json:"join_date" db:"join_date"`
+	Position int              `json:"position"  db:"position"`
 }
 
 func NewGroup(name string, allowedEmails []string, tz string) Group {
@@ -3,20 +3,81 @@ package group
 import (
 	"context"
 	"errors"
+	"time"
 )
 
 var (
 	ErrGroupExists      = errors.New("group already exists")
 	ErrGroupNotFound    = errors.New("group not found")
 	ErrUserNotPermitted = errors.New("user does not have permission")
+	ErrNotMember        = errors.New("user is not a member of the group")
+	ErrInvalidRole      = errors.New("invalid role")
+	ErrLastOwner        = errors.New("group must keep at least one owner")
+	ErrInviteNotFound   = errors.New("invite not found")
+	ErrInviteExpired    = errors.New("invite has expired or been used up")
+	ErrInviteNotForUser = errors.New("invite was sent to a different email")
 )
 
 type GroupService interface {
-	CreateGroup(ctx context.Context, g Group) error
+	// Creates the group with ownerEmail as its owner and only member and returns
+	// it with its ID. Returns ErrGroupExists when the name is taken.
+	CreateGroup(ctx context.Context, g Group, ownerEmail string) (Group, error)
 	GetGroup(ctx context.Context, id uint64) (Group, error)
+	// Returns ErrGroupExists when renaming to the name of another group
+	UpdateGroup(ctx context.Context, id uint64, u GroupUpdate) (Group, error)
 	GetGroupByName(ctx context.Context, name string) (Group, error)
 	GetGroupsByEmail(ctx context.Context, email string) ([]Group, error)
 	GroupContainsUser(ctx context.Context, groupID uint64, userEmail string) (bool, error)
-	AddUserToGroup(ctx context.Context, groupID uint64, userEmail string) error
+	AddUserToGroup(ctx context.Context, groupID uint64, userEmail string, role Role) error
+	GetGroupMembers(ctx context.Context, groupID uint64) ([]Member, error)
+	// Returns ErrNotMember when the user is not in the group
+	GetMemberRole(ctx context.Context, groupID uint64, userEmail string) (Role, error)
+	SetMemberRole(ctx context.Context, groupID uint64, userEmail string, role Role) error
+	// Fails with ErrLastOwner rather than leave the group without an owner
+	RemoveUserFromGroup(ctx context.Context, groupID uint64, userEmail string) error
+	// Makes toEmail an owner and demotes fromEmail to admin
+	TransferOwnership(ctx context.Context, groupID uint64, fromEmail, toEmail string) error
 	DeleteGroup(ctx context.Context, id uint64, userEmail string) error
 }
+
+type InviteService interface {
+	CreateInvite(ctx context.Context, inv Invite) (Invite, error)
+	// Returns ErrInviteNotFound for unknown, revoked and declined invites
+	GetInviteByToken(ctx context.Context, tokenHash string) (Invite, error)
+	// Invites that can still be accepted, newest first
+	GetPendingGroupInvites(ctx context.Context, groupID uint64) ([]Invite, error)
+	GetPendingInvitesByEmail(ctx context.Context, email string) ([]Invite, error)
+	RevokeInvite(ctx context.Context, groupID, id uint64) error
+	// Adds userEmail to the invite's group with the invited role. Users who
+	// are already members keep their role and don't use up the invite.
+	// withToken says the caller presented the invite's token; see CheckAccept.
+	AcceptInvite(
+		ctx context.Context,
+		id uint64,
+		userEmail string,
+		withToken bool,
+	) (Invite, error)
+	// Only invites sent to userEmail can be declined
+	DeclineInvite(ctx context.Context, id uint64, userEmail string) error
+}
+
+type ScheduleService interface {
+	// Returns ErrScheduleNotFound when the group has none
+	GetSchedule(ctx context.Context, groupID uint64) (Schedule, error)
+	SetSchedule(ctx context.Context, sch Schedule) (Schedule, error)
+	DeleteSchedule(ctx context.Context, groupID uint64) error
+	GetEnabledSchedules(ctx context.Context) ([]Schedule, error)
+	// Records that date's session is being opened. Only the first caller for a
+	// date gets true, so several servers can run the scheduler.
+	ClaimScheduledRun(ctx context.Context, groupID uint64, date string) (bool, error)
+	// Gives up a claim on date when its session couldn't be opened, putting
+	// back the previous run date so the next run tries again
+	ReleaseScheduledRun(ctx context.Context, groupID uint64, date string, previous *time.Time) error
+}
+
+type SkipDateService interface {
+	GetSkipDates(ctx context.Context, groupID uint64) ([]SkipDate, error)
+	// Dates the group already skips are left out of the result
+	AddSkipDates(ctx context.Context, groupID uint64, dates []SkipDate) ([]SkipDate, error)
+	DeleteSkipDate(ctx context.Context, groupID, id uint64) error
+}
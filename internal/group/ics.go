@@ -0,0 +1,139 @@
+package group
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// Longest all day event that is expanded into skip dates
+const maxICSEventDays = 31
+
+var ErrInvalidICS = errors.New("invalid iCalendar file")
+
+// Reads the events of an iCalendar file, such as a public holiday feed, as
+// skip dates. Multi-day events become one skip date per day and unbounded
+// yearly or weekly RRULEs carry over as recurrences. Other recurrence rules,
+// and rules that end with UNTIL or COUNT, only keep the first occurrence.
+// Every date must pass SkipDate.Validate.
+func ParseICS(r io.Reader) ([]SkipDate, error) {
+	lines, err := unfoldICS(r)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		dates      []SkipDate
+		inCalendar bool
+		inEvent    bool
+		start, end string
+		summary    string
+		recurrence Recurrence
+	)
+	for _, line := range lines {
+		name, params, value := splitICSLine(line)
+		switch {
+		case name == "BEGIN" && value == "VCALENDAR":
+			inCalendar = true
+		case name == "BEGIN" && value == "VEVENT":
+			inEvent = true
+			start, end, summary, recurrence = "", "", "", RecurrenceOnce
+		case name == "END" && value == "VEVENT":
+			inEvent = false
+			if !inCalendar || start == "" {
+				return nil, ErrInvalidICS
+			}
+			days, err := icsEventDays(start, end)
+			if err != nil {
+				return nil, err
+			}
+			for _, day := range days {
+				d := SkipDate{Date: day, Name: summary, Recurrence: recurrence}
+				if err := d.Validate(); err != nil {
+					return nil, err
+				}
+				dates = append(dates, d)
+			}
+		case !inEvent:
+		case name == "DTSTART":
+			start = icsDate(value)
+		case name == "DTEND" && strings.Contains(params, "VALUE=DATE"):
+			// Only all day events span whole days
+			end = icsDate(value)
+		case name == "SUMMARY":
+			summary = unescapeICS(value)
+		case name == "RRULE":
+			switch {
+			case strings.Contains(value, "UNTIL=") || strings.Contains(value, "COUNT="):
+				// Skip dates can't end, so a bounded rule would skip forever
+			case strings.Contains(value, "FREQ=YEARLY"):
+				recurrence = RecurrenceYearly
+			case strings.Contains(value, "FREQ=WEEKLY"):
+				recurrence = RecurrenceWeekly
+			}
+		}
+	}
+	if !inCalendar {
+		return nil, ErrInvalidICS
+	}
+	return dates, nil
+}
+
+// Joins continuation lines, which start with a space or tab
+func unfoldICS(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// Splits NAME;PARAMS:VALUE
+func splitICSLine(line string) (name, params, value string) {
+	head, value, _ := strings.Cut(line, ":")
+	name, params, _ = strings.Cut(head, ";")
+	return strings.ToUpper(name), strings.ToUpper(params), value
+}
+
+// Takes the date part of a DATE or DATE-TIME value
+func icsDate(value string) string {
+	if len(value) < 8 {
+		return ""
+	}
+	return value[:8]
+}
+
+func icsEventDays(start, end string) ([]string, error) {
+	from, err := time.Parse("20060102", start)
+	if err != nil {
+		return nil, ErrInvalidICS
+	}
+	if end == "" {
+		return []string{from.Format(time.DateOnly)}, nil
+	}
+	to, err := time.Parse("20060102", end)
+	if err != nil {
+		return nil, ErrInvalidICS
+	}
+	days := []string{from.Format(time.DateOnly)}
+	day := from.AddDate(0, 0, 1)
+	for day.Before(to) && len(days) < maxICSEventDays {
+		days = append(days, day.Format(time.DateOnly))
+		day = day.AddDate(0, 0, 1)
+	}
+	return days, nil
+}
+
+func unescapeICS(value string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).
+		Replace(value)
+}
@@ -0,0 +1,82 @@
+package group
+
+import (
+	"strings"
+	"testing"
+)
+
+const testICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20241225\r\n" +
+	"DTEND;VALUE=DATE:20241227\r\n" +
+	"SUMMARY:Christmas\\, Boxing\r\n" +
+	"  Day\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20250101\r\n" +
+	"RRULE:FREQ=YEARLY\r\n" +
+	"SUMMARY:New Year\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;TZID=Europe/London:20241231T090000\r\n" +
+	"DTEND;TZID=Europe/London:20241231T170000\r\n" +
+	"SUMMARY:Offsite\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICS(t *testing.T) {
+	dates, err := ParseICS(strings.NewReader(testICS))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SkipDate{
+		{Date: "2024-12-25", Name: "Christmas, Boxing Day", Recurrence: RecurrenceOnce},
+		{Date: "2024-12-26", Name: "Christmas, Boxing Day", Recurrence: RecurrenceOnce},
+		{Date: "2025-01-01", Name: "New Year", Recurrence: RecurrenceYearly},
+		{Date: "2024-12-31", Name: "Offsite", Recurrence: RecurrenceOnce},
+	}
+	if len(dates) != len(want) {
+		t.Fatalf("Expected %d dates, got %v", len(want), dates)
+	}
+	for i := range want {
+		if dates[i] != want[i] {
+			t.Errorf("Date %d: expected %+v, got %+v", i, want[i], dates[i])
+		}
+	}
+}
+
+func TestParseICSBoundedRules(t *testing.T) {
+	ics := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"DTSTART;VALUE=DATE:20250106\r\n" +
+		"RRULE:FREQ=WEEKLY;COUNT=4\r\n" +
+		"SUMMARY:Sprint planning\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	dates, err := ParseICS(strings.NewReader(ics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dates) != 1 || dates[0].Recurrence != RecurrenceOnce {
+		t.Errorf("Expected only the first occurrence, got %+v", dates)
+	}
+}
+
+func TestParseICSValidatesDates(t *testing.T) {
+	ics := "BEGIN:VCALENDAR\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"DTSTART;VALUE=DATE:20250101\r\n" +
+		"SUMMARY:" + strings.Repeat("x", maxDescriptionLength+1) + "\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+	if _, err := ParseICS(strings.NewReader(ics)); err != ErrDescriptionTooLong {
+		t.Errorf("Expected ErrDescriptionTooLong, got %v", err)
+	}
+}
+
+func TestParseICSRejectsOtherFiles(t *testing.T) {
+	if _, err := ParseICS(strings.NewReader("not a calendar")); err != ErrInvalidICS {
+		t.Errorf("Expected ErrInvalidICS, got %v", err)
+	}
+}
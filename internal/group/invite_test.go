@@ -0,0 +1,41 @@
+package group
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewInvite(t *testing.T) {
+	token, inv := NewInvite(1, "Someone@Example.com", RoleMember, "owner@example.com", time.Hour, 5)
+	if inv.TokenHash != HashInviteToken(token) {
+		t.Error("Invite should store the hash of its token")
+	}
+	if inv.Email == nil || *inv.Email != "someone@example.com" {
+		t.Errorf("Expected lowercased email, got %v", inv.Email)
+	}
+	if inv.MaxUses == nil || *inv.MaxUses != 1 {
+		t.Error("Email invites should only be usable once")
+	}
+
+	_, link := NewInvite(1, "", RoleViewer, "owner@example.com", time.Hour, 0)
+	if link.Email != nil || link.MaxUses != nil {
+		t.Error("Link invites without max uses should be unlimited and not tied to an email")
+	}
+}
+
+func TestInviteCheckAccept(t *testing.T) {
+	_, link := NewInvite(1, "", RoleMember, "owner@example.com", time.Hour, 0)
+	if err := link.CheckAccept("anyone@example.com", false); err != ErrInviteNotFound {
+		t.Errorf("Link invites must not be accepted by id, got %v", err)
+	}
+	if err := link.CheckAccept("anyone@example.com", true); err != nil {
+		t.Errorf("Link invites should be accepted with their token, got %v", err)
+	}
+	_, sent := NewInvite(1, "someone@example.com", RoleMember, "owner@example.com", time.Hour, 0)
+	if err := sent.CheckAccept("Someone@Example.com", false); err != nil {
+		t.Errorf("Invitee should accept by id, got %v", err)
+	}
+	if err := sent.CheckAccept("other@example.com", true); err != ErrInviteNotForUser {
+		t.Errorf("Email invites are only for the invitee, got %v", err)
+	}
+}
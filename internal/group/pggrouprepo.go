@@ -7,10 +7,34 @@ import (
 
 	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-const UNIQUE_VIOLATION = "23505"
+const (
+	UNIQUE_VIOLATION      = "23505"
+	FOREIGN_KEY_VIOLATION = "23503"
+)
+
+// Groups are selected with their member emails folded back into
+// allowed_emails, in display order.
+const selectGroups = `
+	SELECT g.*, ARRAY(
+		SELECT m.email FROM group_members m
+		WHERE m.group_id = g.id
+		ORDER BY m.position, m.join_date
+	) AS allowed_emails
+	FROM groups g`
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == UNIQUE_VIOLATION
+}
+
+func isForeignKeyViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == FOREIGN_KEY_VIOLATION
+}
 
 type PgGroupRepo struct {
 	db *pgxpool.Pool
@@ -20,48 +44,65 @@ func NewPgGroupRepo(db *pgxpool.Pool) *PgGroupRepo {
 	return &PgGroupRepo{db: db}
 }
 
-func (repo *PgGroupRepo) CreateGroup(ctx context.Context, g Group) error {
+// Name uniqueness is left to the groups_name_key constraint so two requests
+// can't both create the same group.
+func (repo *PgGroupRepo) CreateGroup(
+	ctx context.Context,
+	g Group,
+	ownerEmail string,
+) (Group, error) {
 	conn, err := repo.db.Acquire(ctx)
+	if err != nil {
+		return Group{}, err
+	}
 	defer conn.Release()
+	transaction, err := conn.Begin(ctx)
 	if err != nil {
-		return err
+		return Group{}, err
 	}
-	var exists bool
-	err = pgxscan.Get(
+	defer transaction.Rollback(ctx)
+	if err = pgxscan.Get(
 		ctx,
-		conn,
-		&exists,
-		"SELECT true FROM groups WHERE name = $1",
+		transaction,
+		&g.ID,
+		`INSERT INTO groups (name, timezone, description, avatar_url)
+		VALUES ($1, $2, $3, $4) RETURNING id`,
 		g.Name,
-	)
-	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		return err
-	}
-	if exists {
-		return ErrGroupExists
+		g.Timezone,
+		g.Description,
+		g.AvatarURL,
+	); err != nil {
+		if isUniqueViolation(err) {
+			return Group{}, ErrGroupExists
+		}
+		return Group{}, err
 	}
-	_, err = conn.Exec(
+	if _, err = transaction.Exec(
 		ctx,
-		"INSERT INTO groups (name, allowed_emails,timezone) VALUES ($1, $2, $3)",
-		g.Name,
-		g.AllowedEmails,
-		g.Timezone,
-	)
-	return err
+		`INSERT INTO group_members (group_id, email, role, position) VALUES ($1, $2, 'owner', 0)`,
+		g.ID,
+		strings.ToLower(ownerEmail),
+	); err != nil {
+		return Group{}, err
+	}
+	if err = transaction.Commit(ctx); err != nil {
+		return Group{}, err
+	}
+	return repo.GetGroup(ctx, g.ID)
 }
 
 func (repo *PgGroupRepo) GetGroup(ctx context.Context, id uint64) (Group, error) {
 	var g Group
 	conn, err := repo.db.Acquire(ctx)
-	defer conn.Release()
 	if err != nil {
 		return Group{}, err
 	}
+	defer conn.Release()
 	err = pgxscan.Get(
 		ctx,
 		conn,
 		&g,
-		"SELECT * FROM groups WHERE id = $1",
+		selectGroups+" WHERE g.id = $1",
 		id,
 	)
 	if err != nil {
@@ -73,13 +114,49 @@ func (repo *PgGroupRepo) GetGroup(ctx context.Context, id uint64) (Group, error)
 	return g, nil
 }
 
+func (repo *PgGroupRepo) UpdateGroup(ctx context.Context, id uint64, u GroupUpdate) (Group, error) {
+	tag, err := repo.db.Exec(
+		ctx,
+		`UPDATE groups SET
+			name = COALESCE($2, name),
+			timezone = COALESCE($3, timezone),
+			description = COALESCE($4, description),
+			avatar_url = COALESCE($5, avatar_url),
+			facilitator_policy = COALESCE($6, facilitator_policy),
+			facilitator_email = CASE
+				WHEN $7::TEXT IS NULL THEN facilitator_email
+				ELSE NULLIF(lower($7), '')
+			END,
+			speaker_seconds = COALESCE($8, speaker_seconds)
+		WHERE id = $1`,
+		id,
+		u.Name,
+		u.Timezone,
+		u.Description,
+		u.AvatarURL,
+		u.FacilitatorPolicy,
+		u.FacilitatorEmail,
+		u.SpeakerSeconds,
+	)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return Group{}, ErrGroupExists
+		}
+		return Group{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		return Group{}, ErrGroupNotFound
+	}
+	return repo.GetGroup(ctx, id)
+}
+
 func (repo *PgGroupRepo) GetGroupByName(ctx context.Context, name string) (Group, error) {
 	var g Group
 	err := pgxscan.Get(
 		ctx,
 		repo.db,
 		&g,
-		"SELECT * FROM groups WHERE name = $1",
+		selectGroups+" WHERE g.name = $1",
 		name,
 	)
 	if err != nil {
@@ -94,16 +171,18 @@ func (repo *PgGroupRepo) GetGroupByName(ctx context.Context, name string) (Group
 func (repo *PgGroupRepo) GetGroupsByEmail(ctx context.Context, email string) ([]Group, error) {
 	var groups []Group
 	conn, err := repo.db.Acquire(ctx)
-	defer conn.Release()
 	if err != nil {
 		return groups, err
 	}
+	defer conn.Release()
 	err = pgxscan.Select(
 		ctx,
 		conn,
 		&groups,
-		"SELECT * FROM groups WHERE $1 = ANY(allowed_emails)",
-		email,
+		selectGroups+`
+		JOIN group_members me ON me.group_id = g.id AND me.email = $1
+		ORDER BY g.id`,
+		strings.ToLower(email),
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -119,67 +198,215 @@ func (repo *PgGroupRepo) GroupContainsUser(
 	groupID uint64,
 	userEmail string,
 ) (bool, error) {
-	var valid bool
+	_, err := repo.GetMemberRole(ctx, groupID, userEmail)
+	if errors.Is(err, ErrNotMember) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (repo *PgGroupRepo) GetGroupMembers(ctx context.Context, groupID uint64) ([]Member, error) {
+	members := []Member{}
+	err := pgxscan.Select(
+		ctx,
+		repo.db,
+		&members,
+		`SELECT group_id, email, role, join_date, position
+		FROM group_members
+		WHERE group_id = $1
+		ORDER BY position, join_date`,
+		groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// Existing members keep their role
+func (repo *PgGroupRepo) AddUserToGroup(
+	ctx context.Context,
+	groupID uint64,
+	userEmail string,
+	role Role,
+) error {
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+	_, err := repo.db.Exec(
+		ctx,
+		`INSERT INTO group_members (group_id, email, role, position)
+		SELECT $1, $2, $3, COALESCE(MAX(position) + 1, 0) FROM group_members WHERE group_id = $1
+		ON CONFLICT (group_id, email) DO NOTHING`,
+		groupID,
+		strings.ToLower(userEmail),
+		role,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == FOREIGN_KEY_VIOLATION {
+		return ErrGroupNotFound
+	}
+	return err
+}
+
+func (repo *PgGroupRepo) GetMemberRole(
+	ctx context.Context,
+	groupID uint64,
+	userEmail string,
+) (Role, error) {
+	var row struct {
+		Role *Role `db:"role"`
+	}
 	err := pgxscan.Get(
 		ctx,
 		repo.db,
-		&valid,
-		"SELECT $1 = ANY(allowed_emails) FROM groups WHERE id = $2",
-		userEmail,
+		&row,
+		`SELECT m.role
+		FROM groups g
+		LEFT JOIN group_members m ON m.group_id = g.id AND m.email = $2
+		WHERE g.id = $1`,
 		groupID,
+		strings.ToLower(userEmail),
 	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return false, ErrGroupNotFound
+			return "", ErrGroupNotFound
 		}
-		return false, err
+		return "", err
+	}
+	if row.Role == nil {
+		return "", ErrNotMember
 	}
-	return valid, nil
+	return *row.Role, nil
 }
 
-func (repo *PgGroupRepo) AddUserToGroup(
+func (repo *PgGroupRepo) SetMemberRole(
 	ctx context.Context,
 	groupID uint64,
 	userEmail string,
+	role Role,
 ) error {
-	userEmail = strings.ToLower(userEmail)
-	_, err := repo.db.Exec(
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+	tag, err := repo.db.Exec(
 		ctx,
-		`UPDATE groups SET allowed_emails = ARRAY_APPEND(allowed_emails, $1) WHERE id = $2 AND $1 <> ALL(allowed_emails)`,
-		userEmail,
+		"UPDATE group_members SET role = $3 WHERE group_id = $1 AND email = $2",
 		groupID,
+		strings.ToLower(userEmail),
+		role,
 	)
 	if err != nil {
 		return err
 	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotMember
+	}
 	return nil
 }
 
-func (repo *PgGroupRepo) DeleteGroup(ctx context.Context, id uint64, userEmail string) error {
+func (repo *PgGroupRepo) RemoveUserFromGroup(
+	ctx context.Context,
+	groupID uint64,
+	userEmail string,
+) error {
 	conn, err := repo.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Release()
+	transaction, err := conn.Begin(ctx)
 	if err != nil {
 		return err
 	}
-	var isAllowed bool
-	err = pgxscan.Get(
+	defer transaction.Rollback(ctx)
+	// Lock the group's owners so two owners can't both leave at once
+	var owners []string
+	if err = pgxscan.Select(
 		ctx,
-		conn,
-		&isAllowed,
-		"SELECT $1 = ANY(allowed_emails) FROM groups WHERE id = $2",
+		transaction,
+		&owners,
+		"SELECT email FROM group_members WHERE group_id = $1 AND role = $2 FOR UPDATE",
+		groupID,
+		RoleOwner,
+	); err != nil {
+		return err
+	}
+	userEmail = strings.ToLower(userEmail)
+	if len(owners) == 1 && owners[0] == userEmail {
+		return ErrLastOwner
+	}
+	tag, err := transaction.Exec(
+		ctx,
+		"DELETE FROM group_members WHERE group_id = $1 AND email = $2",
+		groupID,
 		userEmail,
-		id,
 	)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return ErrGroupNotFound
-		}
 		return err
 	}
-	if !isAllowed {
+	if tag.RowsAffected() == 0 {
+		return ErrNotMember
+	}
+	return transaction.Commit(ctx)
+}
+
+func (repo *PgGroupRepo) TransferOwnership(
+	ctx context.Context,
+	groupID uint64,
+	fromEmail, toEmail string,
+) error {
+	conn, err := repo.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+	transaction, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer transaction.Rollback(ctx)
+	tag, err := transaction.Exec(
+		ctx,
+		"UPDATE group_members SET role = $3 WHERE group_id = $1 AND email = $2",
+		groupID,
+		strings.ToLower(toEmail),
+		RoleOwner,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrNotMember
+	}
+	if _, err = transaction.Exec(
+		ctx,
+		"UPDATE group_members SET role = $3 WHERE group_id = $1 AND email = $2 AND role = $4",
+		groupID,
+		strings.ToLower(fromEmail),
+		RoleAdmin,
+		RoleOwner,
+	); err != nil {
+		return err
+	}
+	return transaction.Commit(ctx)
+}
+
+func (repo *PgGroupRepo) DeleteGroup(ctx context.Context, id uint64, userEmail string) error {
+	role, err := repo.GetMemberRole(ctx, id, userEmail)
+	if errors.Is(err, ErrNotMember) {
+		return ErrUserNotPermitted
+	}
+	if err != nil {
+		return err
+	}
+	if !role.Can(PermDeleteGroup) {
 		return ErrUserNotPermitted
 	}
-	_, err = conn.Exec(
+	_, err = repo.db.Exec(
 		ctx,
 		"DELETE FROM groups WHERE id = $1",
 		id,
@@ -0,0 +1,232 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const selectInvites = `
+	SELECT i.id, i.group_id, g.name AS group_name, i.email, i.role, i.token_hash,
+		i.created_by, i.create_date, i.expires_at, i.max_uses, i.uses
+	FROM group_invites i
+	JOIN groups g ON g.id = i.group_id`
+
+const invitePending = `
+	i.revoked_at IS NULL AND i.declined_at IS NULL AND i.expires_at > NOW()
+	AND (i.max_uses IS NULL OR i.uses < i.max_uses)`
+
+type PgInviteRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPgInviteRepo(db *pgxpool.Pool) *PgInviteRepo {
+	return &PgInviteRepo{db: db}
+}
+
+func (repo *PgInviteRepo) CreateInvite(ctx context.Context, inv Invite) (Invite, error) {
+	err := pgxscan.Get(
+		ctx,
+		repo.db,
+		&inv,
+		`WITH i AS (
+			INSERT INTO group_invites
+			(group_id, email, role, token_hash, created_by, create_date, expires_at, max_uses)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id
+		)
+		SELECT i.id, g.name AS group_name FROM i, groups g WHERE g.id = $1`,
+		inv.GroupID,
+		inv.Email,
+		inv.Role,
+		inv.TokenHash,
+		inv.CreatedBy,
+		inv.CreateDate,
+		inv.ExpiresAt,
+		inv.MaxUses,
+	)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == FOREIGN_KEY_VIOLATION {
+		return Invite{}, ErrGroupNotFound
+	}
+	if err != nil {
+		return Invite{}, err
+	}
+	return inv, nil
+}
+
+func (repo *PgInviteRepo) GetInviteByToken(ctx context.Context, tokenHash string) (Invite, error) {
+	var inv Invite
+	err := pgxscan.Get(
+		ctx,
+		repo.db,
+		&inv,
+		selectInvites+`
+		WHERE i.token_hash = $1 AND i.revoked_at IS NULL AND i.declined_at IS NULL`,
+		tokenHash,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Invite{}, ErrInviteNotFound
+		}
+		return Invite{}, err
+	}
+	return inv, nil
+}
+
+func (repo *PgInviteRepo) GetPendingGroupInvites(
+	ctx context.Context,
+	groupID uint64,
+) ([]Invite, error) {
+	invites := []Invite{}
+	err := pgxscan.Select(
+		ctx,
+		repo.db,
+		&invites,
+		selectInvites+" WHERE i.group_id = $1 AND"+invitePending+" ORDER BY i.create_date DESC",
+		groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+func (repo *PgInviteRepo) GetPendingInvitesByEmail(
+	ctx context.Context,
+	email string,
+) ([]Invite, error) {
+	invites := []Invite{}
+	err := pgxscan.Select(
+		ctx,
+		repo.db,
+		&invites,
+		selectInvites+`
+		WHERE i.email = $1 AND`+invitePending+`
+		AND NOT EXISTS (
+			SELECT 1 FROM group_members m WHERE m.group_id = i.group_id AND m.email = i.email
+		)
+		ORDER BY i.create_date DESC`,
+		strings.ToLower(email),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return invites, nil
+}
+
+func (repo *PgInviteRepo) RevokeInvite(ctx context.Context, groupID, id uint64) error {
+	tag, err := repo.db.Exec(
+		ctx,
+		`UPDATE group_invites SET revoked_at = NOW()
+		WHERE id = $1 AND group_id = $2 AND revoked_at IS NULL`,
+		id,
+		groupID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInviteNotFound
+	}
+	return nil
+}
+
+func (repo *PgInviteRepo) AcceptInvite(
+	ctx context.Context,
+	id uint64,
+	userEmail string,
+	withToken bool,
+) (Invite, error) {
+	conn, err := repo.db.Acquire(ctx)
+	if err != nil {
+		return Invite{}, err
+	}
+	defer conn.Release()
+	transaction, err := conn.Begin(ctx)
+	if err != nil {
+		return Invite{}, err
+	}
+	defer transaction.Rollback(ctx)
+
+	// Locking the invite keeps concurrent accepts from going over max_uses
+	var inv struct {
+		Invite
+		Usable bool `db:"usable"`
+	}
+	err = pgxscan.Get(
+		ctx,
+		transaction,
+		&inv,
+		`SELECT i.id, i.group_id, g.name AS group_name, i.email, i.role, i.token_hash,
+			i.created_by, i.create_date, i.expires_at, i.max_uses, i.uses,
+			(i.expires_at > NOW() AND (i.max_uses IS NULL OR i.uses < i.max_uses)) AS usable
+		FROM group_invites i
+		JOIN groups g ON g.id = i.group_id
+		WHERE i.id = $1 AND i.revoked_at IS NULL AND i.declined_at IS NULL
+		FOR UPDATE OF i`,
+		id,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Invite{}, ErrInviteNotFound
+		}
+		return Invite{}, err
+	}
+	userEmail = strings.ToLower(userEmail)
+	if err := inv.CheckAccept(userEmail, withToken); err != nil {
+		return Invite{}, err
+	}
+	if !inv.Usable {
+		return Invite{}, ErrInviteExpired
+	}
+	tag, err := transaction.Exec(
+		ctx,
+		`INSERT INTO group_members (group_id, email, role, position)
+		SELECT $1, $2, $3, COALESCE(MAX(position) + 1, 0) FROM group_members WHERE group_id = $1
+		ON CONFLICT (group_id, email) DO NOTHING`,
+		inv.GroupID,
+		userEmail,
+		inv.Role,
+	)
+	if err != nil {
+		return Invite{}, err
+	}
+	if tag.RowsAffected() == 0 {
+		return inv.Invite, nil
+	}
+	if _, err = transaction.Exec(
+		ctx,
+		"UPDATE group_invites SET uses = uses + 1 WHERE id = $1",
+		inv.ID,
+	); err != nil {
+		return Invite{}, err
+	}
+	if err = transaction.Commit(ctx); err != nil {
+		return Invite{}, err
+	}
+	inv.Uses++
+	return inv.Invite, nil
+}
+
+func (repo *PgInviteRepo) DeclineInvite(ctx context.Context, id uint64, userEmail string) error {
+	tag, err := repo.db.Exec(
+		ctx,
+		`UPDATE group_invites SET declined_at = NOW()
+		WHERE id = $1 AND email = $2 AND revoked_at IS NULL AND declined_at IS NULL
+		AND (max_uses IS NULL OR uses < max_uses)`,
+		id,
+		strings.ToLower(userEmail),
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInviteNotFound
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package group
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const selectSchedules = `
+	SELECT s.group_id, s.weekdays, s.local_time, s.enabled, s.last_run_date, g.timezone
+	FROM group_schedules s
+	JOIN groups g ON g.id = s.group_id`
+
+type PgScheduleRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPgScheduleRepo(db *pgxpool.Pool) *PgScheduleRepo {
+	return &PgScheduleRepo{db: db}
+}
+
+func (repo *PgScheduleRepo) GetSchedule(ctx context.Context, groupID uint64) (Schedule, error) {
+	var sch Schedule
+	err := pgxscan.Get(ctx, repo.db, &sch, selectSchedules+" WHERE s.group_id = $1", groupID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Schedule{}, ErrScheduleNotFound
+		}
+		return Schedule{}, err
+	}
+	return sch, nil
+}
+
+// Replacing a schedule keeps its last run, so moving the time later in the day
+// doesn't open a second session.
+func (repo *PgScheduleRepo) SetSchedule(ctx context.Context, sch Schedule) (Schedule, error) {
+	_, err := repo.db.Exec(
+		ctx,
+		`INSERT INTO group_schedules (group_id, weekdays, local_time, enabled)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (group_id) DO UPDATE SET
+			weekdays = EXCLUDED.weekdays,
+			local_time = EXCLUDED.local_time,
+			enabled = EXCLUDED.enabled`,
+		sch.GroupID,
+		sch.Weekdays,
+		sch.Time,
+		sch.Enabled,
+	)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return Schedule{}, ErrGroupNotFound
+		}
+		return Schedule{}, err
+	}
+	return repo.GetSchedule(ctx, sch.GroupID)
+}
+
+func (repo *PgScheduleRepo) DeleteSchedule(ctx context.Context, groupID uint64) error {
+	tag, err := repo.db.Exec(ctx, "DELETE FROM group_schedules WHERE group_id = $1", groupID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+func (repo *PgScheduleRepo) GetEnabledSchedules(ctx context.Context) ([]Schedule, error) {
+	schedules := []Schedule{}
+	err := pgxscan.Select(ctx, repo.db, &schedules, selectSchedules+" WHERE s.enabled")
+	if err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+func (repo *PgScheduleRepo) ClaimScheduledRun(
+	ctx context.Context,
+	groupID uint64,
+	date string,
+) (bool, error) {
+	tag, err := repo.db.Exec(
+		ctx,
+		`UPDATE group_schedules SET last_run_date = $2::DATE
+		WHERE group_id = $1 AND (last_run_date IS NULL OR last_run_date < $2::DATE)`,
+		groupID,
+		date,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (repo *PgScheduleRepo) ReleaseScheduledRun(
+	ctx context.Context,
+	groupID uint64,
+	date string,
+	previous *time.Time,
+) error {
+	_, err := repo.db.Exec(
+		ctx,
+		`UPDATE group_schedules SET last_run_date = $3
+		WHERE group_id = $1 AND last_run_date = $2::DATE`,
+		groupID,
+		date,
+		previous,
+	)
+	return err
+}
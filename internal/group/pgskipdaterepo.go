@@ -0,0 +1,87 @@
+package group
+
+import (
+	"context"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PgSkipDateRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPgSkipDateRepo(db *pgxpool.Pool) *PgSkipDateRepo {
+	return &PgSkipDateRepo{db: db}
+}
+
+func (repo *PgSkipDateRepo) GetSkipDates(ctx context.Context, groupID uint64) ([]SkipDate, error) {
+	dates := []SkipDate{}
+	err := pgxscan.Select(
+		ctx,
+		repo.db,
+		&dates,
+		`SELECT id, group_id, date::TEXT AS date, name, recurrence
+		FROM group_skip_dates
+		WHERE group_id = $1
+		ORDER BY date, id`,
+		groupID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return dates, nil
+}
+
+func (repo *PgSkipDateRepo) AddSkipDates(
+	ctx context.Context,
+	groupID uint64,
+	dates []SkipDate,
+) ([]SkipDate, error) {
+	days := make([]string, len(dates))
+	names := make([]string, len(dates))
+	recurrences := make([]string, len(dates))
+	for i, d := range dates {
+		days[i] = d.Date
+		names[i] = d.Name
+		recurrences[i] = string(d.Recurrence)
+	}
+	added := []SkipDate{}
+	err := pgxscan.Select(
+		ctx,
+		repo.db,
+		&added,
+		`INSERT INTO group_skip_dates (group_id, date, name, recurrence)
+		SELECT $1, d.date, d.name, d.recurrence
+		FROM unnest($2::DATE[], $3::TEXT[], $4::TEXT[]) AS d(date, name, recurrence)
+		ON CONFLICT (group_id, date, recurrence) DO NOTHING
+		RETURNING id, group_id, date::TEXT AS date, name, recurrence`,
+		groupID,
+		days,
+		names,
+		recurrences,
+	)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, err
+	}
+	return added, nil
+}
+
+func (repo *PgSkipDateRepo) DeleteSkipDate(ctx context.Context, groupID, id uint64) error {
+	tag, err := repo.db.Exec(
+		ctx,
+		"DELETE FROM group_skip_dates WHERE id = $1 AND group_id = $2",
+		id,
+		groupID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSkipDateNotFound
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package group
+
+import "slices"
+
+type Role string
+
+const (
+	RoleOwner  Role = "owner"
+	RoleAdmin  Role = "admin"
+	RoleMember Role = "member"
+	RoleViewer Role = "viewer"
+)
+
+type Permission string
+
+const (
+	PermDeleteGroup    Permission = "delete_group"
+	PermTransferOwner  Permission = "transfer_owner"
+	PermManageMembers  Permission = "manage_members"
+	PermManageSettings Permission = "manage_settings"
+	// Start sessions and control a running standup
+	PermRunSession Permission = "run_session"
+	// Reopen or lock sessions that have been closed
+	PermManageSessions Permission = "manage_sessions"
+	// Write another member's submission on their behalf
+	PermEditOthers Permission = "edit_others"
+	PermSubmit     Permission = "submit"
+	// See the group, its sessions and watch the live room
+	PermRead Permission = "read"
+)
+
+var permissions = map[Role][]Permission{
+	RoleOwner: {
+		PermDeleteGroup,
+		PermTransferOwner,
+		PermManageMembers,
+		PermManageSettings,
+		PermRunSession,
+		PermManageSessions,
+		PermEditOthers,
+		PermSubmit,
+		PermRead,
+	},
+	RoleAdmin: {
+		PermManageMembers,
+		PermManageSettings,
+		PermRunSession,
+		PermManageSessions,
+		PermEditOthers,
+		PermSubmit,
+		PermRead,
+	},
+	RoleMember: {PermRunSession, PermSubmit, PermRead},
+	RoleViewer: {PermRead},
+}
+
+// Returns the roles that grant p
+func RolesWith(p Permission) []Role {
+	roles := []Role{}
+	for _, r := range []Role{RoleOwner, RoleAdmin, RoleMember, RoleViewer} {
+		if r.Can(p) {
+			roles = append(roles, r)
+		}
+	}
+	return roles
+}
+
+func (r Role) Valid() bool {
+	_, ok := permissions[r]
+	return ok
+}
+
+func (r Role) Can(p Permission) bool {
+	return slices.Contains(permissions[r], p)
+}
+
+// Whether r may take a member with role target out of the group
+func (r Role) CanRemove(target Role) bool {
+	return r.CanAssign(target, RoleViewer)
+}
+
+// Owners may change anyone's role. Admins may only move people between member
+// and viewer.
+func (r Role) CanAssign(from, to Role) bool {
+	switch r {
+	case RoleOwner:
+		return true
+	case RoleAdmin:
+		return (from == RoleMember || from == RoleViewer) && (to == RoleMember || to == RoleViewer)
+	default:
+		return false
+	}
+}
@@ -0,0 +1,56 @@
+package group
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPermissionMatrix(t *testing.T) {
+	tests := []struct {
+		role Role
+		perm Permission
+		want bool
+	}{
+		{RoleOwner, PermDeleteGroup, true},
+		{RoleAdmin, PermDeleteGroup, false},
+		{RoleAdmin, PermManageMembers, true},
+		{RoleMember, PermManageMembers, false},
+		{RoleAdmin, PermManageSessions, true},
+		{RoleMember, PermManageSessions, false},
+		{RoleMember, PermSubmit, true},
+		{RoleViewer, PermSubmit, false},
+		{RoleViewer, PermRead, true},
+		{Role("unknown"), PermRead, false},
+	}
+	for _, tt := range tests {
+		if got := tt.role.Can(tt.perm); got != tt.want {
+			t.Errorf("%s.Can(%s) = %v, want %v", tt.role, tt.perm, got, tt.want)
+		}
+	}
+}
+
+func TestCanAssign(t *testing.T) {
+	if !RoleAdmin.CanAssign(RoleMember, RoleViewer) {
+		t.Error("Admins should be able to demote members to viewers")
+	}
+	if RoleAdmin.CanAssign(RoleMember, RoleAdmin) {
+		t.Error("Admins should not be able to promote to admin")
+	}
+	if RoleAdmin.CanAssign(RoleOwner, RoleMember) {
+		t.Error("Admins should not be able to demote owners")
+	}
+	if RoleMember.CanAssign(RoleViewer, RoleMember) {
+		t.Error("Members should not be able to assign roles")
+	}
+	if !RoleAdmin.CanRemove(RoleMember) || RoleAdmin.CanRemove(RoleAdmin) {
+		t.Error("Admins should only be able to remove members and viewers")
+	}
+}
+
+func TestRolesWith(t *testing.T) {
+	got := RolesWith(PermRunSession)
+	want := []Role{RoleOwner, RoleAdmin, RoleMember}
+	if !slices.Equal(got, want) {
+		t.Errorf("RolesWith(%s) = %v, want %v", PermRunSession, got, want)
+	}
+}
@@ -0,0 +1,74 @@
+package group
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"time"
+)
+
+const scheduleTimeLayout = "15:04"
+
+// Same as the local_time column's check, which time.Parse is laxer than
+var scheduleTimePattern = regexp.MustCompile(`^([01][0-9]|2[0-3]):[0-5][0-9]$`)
+
+var (
+	ErrScheduleNotFound = errors.New("group has no schedule")
+	ErrInvalidWeekdays  = errors.New("weekdays must be between 0 (Sunday) and 6 (Saturday)")
+	ErrInvalidTime      = errors.New("time must be HH:MM")
+)
+
+// A Schedule opens a group's session on the given weekdays once Time has
+// passed in the group's timezone.
+type Schedule struct {
+	GroupID  uint64 `json:"group_id" db:"group_id"`
+	Weekdays []int  `json:"weekdays" db:"weekdays"`
+	Time     string `json:"time"     db:"local_time"`
+	Enabled  bool   `json:"enabled"  db:"enabled"`
+	// Taken from the group
+	Timezone    string     `json:"timezone" db:"timezone"`
+	LastRunDate *time.Time `json:"-"        db:"last_run_date"`
+}
+
+func (sch Schedule) Validate() error {
+	if len(sch.Weekdays) == 0 {
+		return ErrInvalidWeekdays
+	}
+	for _, day := range sch.Weekdays {
+		if day < int(time.Sunday) || day > int(time.Saturday) {
+			return ErrInvalidWeekdays
+		}
+	}
+	if !scheduleTimePattern.MatchString(sch.Time) {
+		return ErrInvalidTime
+	}
+	return nil
+}
+
+// Reports whether a session should be opened at now and for which group local
+// date, formatted as YYYY-MM-DD. Each date is only due once.
+func (sch Schedule) Due(now time.Time) (string, bool) {
+	if !sch.Enabled {
+		return "", false
+	}
+	loc, err := time.LoadLocation(sch.Timezone)
+	if err != nil {
+		return "", false
+	}
+	at, err := time.Parse(scheduleTimeLayout, sch.Time)
+	if err != nil {
+		return "", false
+	}
+	local := now.In(loc)
+	if !slices.Contains(sch.Weekdays, int(local.Weekday())) {
+		return "", false
+	}
+	if local.Hour()*60+local.Minute() < at.Hour()*60+at.Minute() {
+		return "", false
+	}
+	date := local.Format(time.DateOnly)
+	if sch.LastRunDate != nil && sch.LastRunDate.Format(time.DateOnly) >= date {
+		return "", false
+	}
+	return date, true
+}
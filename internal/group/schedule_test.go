@@ -0,0 +1,52 @@
+package group
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleDue(t *testing.T) {
+	sch := Schedule{
+		Weekdays: []int{int(time.Monday), int(time.Wednesday)},
+		Time:     "09:30",
+		Enabled:  true,
+		Timezone: "America/New_York",
+	}
+	// Monday 2024-12-02 09:45 in New York
+	now := time.Date(2024, 12, 2, 14, 45, 0, 0, time.UTC)
+	date, due := sch.Due(now)
+	if !due || date != "2024-12-02" {
+		t.Errorf("Expected due on 2024-12-02, got %q %v", date, due)
+	}
+	if _, due := sch.Due(now.Add(-30 * time.Minute)); due {
+		t.Error("Should not be due before the scheduled time")
+	}
+	if _, due := sch.Due(now.Add(24 * time.Hour)); due {
+		t.Error("Should not be due on a Tuesday")
+	}
+	lastRun := time.Date(2024, 12, 2, 0, 0, 0, 0, time.UTC)
+	sch.LastRunDate = &lastRun
+	if _, due := sch.Due(now); due {
+		t.Error("Should only be due once per date")
+	}
+	// Late Monday evening in New York is already Tuesday in UTC
+	sch.LastRunDate = nil
+	date, due = sch.Due(time.Date(2024, 12, 3, 3, 0, 0, 0, time.UTC))
+	if !due || date != "2024-12-02" {
+		t.Errorf("Expected the group's local date, got %q %v", date, due)
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	if err := (Schedule{Weekdays: []int{7}, Time: "09:00"}).Validate(); err != ErrInvalidWeekdays {
+		t.Errorf("Expected ErrInvalidWeekdays, got %v", err)
+	}
+	for _, at := range []string{"9am", "9:30", "24:00"} {
+		if err := (Schedule{Weekdays: []int{1}, Time: at}).Validate(); err != ErrInvalidTime {
+			t.Errorf("Expected ErrInvalidTime for %q, got %v", at, err)
+		}
+	}
+	if err := (Schedule{Weekdays: []int{1}, Time: "09:30"}).Validate(); err != nil {
+		t.Errorf("Expected 09:30 to be valid, got %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package group
+
+import (
+	"errors"
+	"time"
+)
+
+type Recurrence string
+
+const (
+	RecurrenceOnce   Recurrence = "once"
+	RecurrenceWeekly Recurrence = "weekly"
+	RecurrenceYearly Recurrence = "yearly"
+)
+
+var (
+	ErrSkipDateNotFound  = errors.New("skip date not found")
+	ErrInvalidDate       = errors.New("date must be YYYY-MM-DD")
+	ErrInvalidRecurrence = errors.New("recurrence must be once, weekly or yearly")
+)
+
+// A SkipDate is a day, in the group's timezone, with no standup. Recurring
+// skip dates repeat from Date on.
+type SkipDate struct {
+	ID         uint64     `json:"id"         db:"id"`
+	GroupID    uint64     `json:"group_id"   db:"group_id"`
+	Date       string     `json:"date"       db:"date"`
+	Name       string     `json:"name"       db:"name"`
+	Recurrence Recurrence `json:"recurrence" db:"recurrence"`
+}
+
+func (d SkipDate) Validate() error {
+	if _, err := time.Parse(time.DateOnly, d.Date); err != nil {
+		return ErrInvalidDate
+	}
+	switch d.Recurrence {
+	case RecurrenceOnce, RecurrenceWeekly, RecurrenceYearly:
+	default:
+		return ErrInvalidRecurrence
+	}
+	return ValidateDescription(d.Name)
+}
@@ -0,0 +1,128 @@
+package group
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	maxNameLength        = 100
+	maxDescriptionLength = 1000
+	maxAvatarURLLength   = 2048
+	maxInitialMembers    = 200
+	minSpeakerSeconds    = 10
+	maxSpeakerSeconds    = 3600
+)
+
+var (
+	ErrInvalidName        = errors.New("name must be between 1 and 100 characters")
+	ErrInvalidTimezone    = errors.New("timezone must be an IANA time zone")
+	ErrDescriptionTooLong = errors.New("description must be at most 1000 characters")
+	ErrInvalidAvatarURL   = errors.New("avatar_url must be an http or https URL")
+	ErrInvalidEmail       = errors.New("invalid email")
+	ErrTooManyMembers     = errors.New("at most 200 allowed_emails can be given")
+	ErrInvalidSpeakerTime = errors.New("speaker_seconds must be between 10 and 3600")
+)
+
+func ValidateName(name string) error {
+	if strings.TrimSpace(name) == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return ErrInvalidName
+	}
+	return nil
+}
+
+// LoadLocation treats "" as UTC and "Local" as the server's zone, neither of
+// which Postgres understands the same way.
+func ValidateTimezone(tz string) error {
+	if tz == "" || tz == "Local" {
+		return ErrInvalidTimezone
+	}
+	if _, err := time.LoadLocation(tz); err != nil {
+		return ErrInvalidTimezone
+	}
+	return nil
+}
+
+func ValidateDescription(description string) error {
+	if utf8.RuneCountInString(description) > maxDescriptionLength {
+		return ErrDescriptionTooLong
+	}
+	return nil
+}
+
+// An empty URL clears the avatar
+func ValidateAvatarURL(avatarURL string) error {
+	if avatarURL == "" {
+		return nil
+	}
+	if len(avatarURL) > maxAvatarURLLength {
+		return ErrInvalidAvatarURL
+	}
+	u, err := url.Parse(avatarURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return ErrInvalidAvatarURL
+	}
+	return nil
+}
+
+func (u GroupUpdate) Validate() error {
+	if u.Name != nil {
+		if err := ValidateName(*u.Name); err != nil {
+			return err
+		}
+	}
+	if u.Timezone != nil {
+		if err := ValidateTimezone(*u.Timezone); err != nil {
+			return err
+		}
+	}
+	if u.Description != nil {
+		if err := ValidateDescription(*u.Description); err != nil {
+			return err
+		}
+	}
+	if u.AvatarURL != nil {
+		if err := ValidateAvatarURL(*u.AvatarURL); err != nil {
+			return err
+		}
+	}
+	if u.FacilitatorPolicy != nil && !u.FacilitatorPolicy.Valid() {
+		return ErrInvalidFacilitatorPolicy
+	}
+	if u.SpeakerSeconds != nil &&
+		(*u.SpeakerSeconds < minSpeakerSeconds || *u.SpeakerSeconds > maxSpeakerSeconds) {
+		return ErrInvalidSpeakerTime
+	}
+	return nil
+}
+
+// Checks a group about to be created. AllowedEmails must be bare addresses.
+func (g Group) Validate() error {
+	if err := ValidateName(g.Name); err != nil {
+		return err
+	}
+	if err := ValidateTimezone(g.Timezone); err != nil {
+		return err
+	}
+	if err := ValidateDescription(g.Description); err != nil {
+		return err
+	}
+	if err := ValidateAvatarURL(g.AvatarURL); err != nil {
+		return err
+	}
+	if len(g.AllowedEmails) > maxInitialMembers {
+		return ErrTooManyMembers
+	}
+	for _, email := range g.AllowedEmails {
+		addr, err := mail.ParseAddress(email)
+		if err != nil || addr.Address != email {
+			return fmt.Errorf("%w: %q", ErrInvalidEmail, email)
+		}
+	}
+	return nil
+}
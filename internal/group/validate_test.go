@@ -0,0 +1,49 @@
+package group
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateTimezone(t *testing.T) {
+	for _, tz := range []string{"America/New_York", "Europe/Berlin", "UTC"} {
+		if err := ValidateTimezone(tz); err != nil {
+			t.Errorf("%q should be valid: %v", tz, err)
+		}
+	}
+	for _, tz := range []string{"", "Local", "Mars/Olympus_Mons", "EST5EDT,M3.2.0"} {
+		if err := ValidateTimezone(tz); err == nil {
+			t.Errorf("%q should be invalid", tz)
+		}
+	}
+}
+
+func TestGroupUpdateValidate(t *testing.T) {
+	blank := "  "
+	if err := (GroupUpdate{Name: &blank}).Validate(); err != ErrInvalidName {
+		t.Errorf("Expected ErrInvalidName, got %v", err)
+	}
+	avatar := "javascript:alert(1)"
+	if err := (GroupUpdate{AvatarURL: &avatar}).Validate(); err != ErrInvalidAvatarURL {
+		t.Errorf("Expected ErrInvalidAvatarURL, got %v", err)
+	}
+	clear := ""
+	if err := (GroupUpdate{AvatarURL: &clear, Description: &clear}).Validate(); err != nil {
+		t.Errorf("Clearing the avatar and description should be allowed: %v", err)
+	}
+}
+
+func TestGroupValidate(t *testing.T) {
+	g := NewGroup("Team", []string{"a@example.com"}, "Europe/London")
+	if err := g.Validate(); err != nil {
+		t.Errorf("Expected valid group: %v", err)
+	}
+	g.AllowedEmails = append(g.AllowedEmails, "Someone <b@example.com>")
+	if err := g.Validate(); !errors.Is(err, ErrInvalidEmail) {
+		t.Errorf("Expected ErrInvalidEmail, got %v", err)
+	}
+	g = NewGroup("Team", nil, "")
+	if err := g.Validate(); err != ErrInvalidTimezone {
+		t.Errorf("Expected ErrInvalidTimezone, got %v", err)
+	}
+}
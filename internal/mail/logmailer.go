@@ -0,0 +1,51 @@
+package mail
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// LogMailer logs messages instead of sending them, for local development
+type LogMailer struct {
+	logger *slog.Logger
+}
+
+func NewLogMailer(logger *slog.Logger) *LogMailer {
+	return &LogMailer{logger: logger}
+}
+
+func (m *LogMailer) Send(ctx context.Context, msg Message) error {
+	m.logger.Info("Mail", "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// FileMailer appends every message to a file, mbox style
+type FileMailer struct {
+	mu   sync.Mutex
+	path string
+	from string
+}
+
+func NewFileMailer(path, from string) *FileMailer {
+	return &FileMailer{path: path, from: from}
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.WriteString("From " + m.from + "\r\n"); err != nil {
+		return err
+	}
+	if _, err := f.Write(msg.Bytes(m.from)); err != nil {
+		return err
+	}
+	_, err = f.WriteString("\r\n\r\n")
+	return err
+}
@@ -0,0 +1,114 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// A minimal identity provider that issues an ID token for any code whose
+// PKCE verifier matches the challenge it was issued for.
+type mockIDP struct {
+	*httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+}
+
+func newMockIDP(t *testing.T) *mockIDP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &mockIDP{key: key}
+	mux := http.NewServeMux()
+	idp.Server = httptest.NewServer(mux)
+	t.Cleanup(idp.Close)
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.URL,
+			"authorization_endpoint": idp.URL + "/authorize",
+			"token_endpoint":         idp.URL + "/token",
+			"jwks_uri":               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if Challenge(r.Form.Get("code_verifier")) != idp.challenge {
+			http.Error(w, "invalid_grant", http.StatusBadRequest)
+			return
+		}
+		now := time.Now()
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            idp.URL,
+			"aud":            "client",
+			"sub":            "123",
+			"email":          "Someone@Example.com",
+			"email_verified": true,
+			"name":           "Someone",
+			"nonce":          idp.nonce,
+			"iat":            now.Unix(),
+			"exp":            now.Add(time.Minute).Unix(),
+		})
+		token.Header["kid"] = "test"
+		raw, _ := token.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": raw})
+	})
+	return idp
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := newMockIDP(t)
+	p := NewProvider(Config{
+		Name:        "mock",
+		IssuerURL:   idp.URL,
+		ClientID:    "client",
+		RedirectURL: "http://localhost/callback",
+	}, idp.Client())
+	ctx := context.Background()
+	verifier, nonce := RandomString(), RandomString()
+	authURL, err := p.AuthCodeURL(ctx, "state", nonce, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	idp.challenge = u.Query().Get("code_challenge")
+	idp.nonce = u.Query().Get("nonce")
+
+	claims, err := p.Exchange(ctx, "code", verifier, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Email != "someone@example.com" || !claims.EmailVerified || claims.Subject != "123" {
+		t.Errorf("Unexpected claims %+v", claims)
+	}
+
+	if _, err := p.Exchange(ctx, "code", verifier, "other nonce"); err != ErrNonceMismatch {
+		t.Errorf("Expected nonce mismatch, got %v", err)
+	}
+	if _, err := p.Exchange(ctx, "code", "wrong verifier", nonce); err == nil {
+		t.Error("Exchange with the wrong PKCE verifier should fail")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 
+	"github.com/dilithaw123/broccoli-backend/internal/group"
 	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -69,29 +70,71 @@ func (repo *PgSessionRepo) GetSessionByGroupID(
 	return s, nil
 }
 
+func (repo *PgSessionRepo) ListSessions(
+	ctx context.Context,
+	groupID uint64,
+	opts ListOptions,
+) ([]Session, error) {
+	sessions := []Session{}
+	err := pgxscan.Select(
+		ctx,
+		repo.db,
+		&sessions,
+		`SELECT s.* FROM sessions s
+		JOIN groups g ON g.id = s.group_id
+		WHERE s.group_id = $1
+		AND (s.create_date at time zone g.timezone)::date
+			BETWEEN COALESCE(NULLIF($2, '')::date, '-infinity')
+			AND COALESCE(NULLIF($3, '')::date, 'infinity')
+		AND ($4 = 0 OR s.id < $4)
+		ORDER BY s.id DESC
+		LIMIT $5`,
+		groupID,
+		opts.From,
+		opts.To,
+		opts.BeforeID,
+		opts.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// Returns the existing session when the group already has one that local day
+// and ErrSkipDate when the day is on the group's skip calendar.
 func (repo *PgSessionRepo) CreateSession(ctx context.Context, s Session) (uint64, error) {
 	conn, err := repo.db.Acquire(ctx)
-	defer conn.Release()
 	if err != nil {
 		return 0, err
 	}
-	var id uint64
+	defer conn.Release()
+	var existing struct {
+		ID   *uint64 `db:"id"`
+		Skip bool    `db:"skip"`
+	}
 	err = pgxscan.Get(
 		ctx,
 		conn,
-		&id,
-		`SELECT s.id from sessions s
-		JOIN groups g ON s.group_id = g.id
-		WHERE s.group_id = $1
-		AND (s.create_date at time zone g.timezone)::date = ($2 at time zone g.timezone)::date`,
+		&existing,
+		`SELECT
+			(SELECT s.id FROM sessions s
+			WHERE s.group_id = g.id
+			AND (s.create_date at time zone g.timezone)::date = ($2 at time zone g.timezone)::date
+			LIMIT 1) AS id,
+			is_skip_date(g.id, ($2 at time zone g.timezone)::date) AS skip
+		FROM groups g WHERE g.id = $1`,
 		s.GroupID,
 		s.CreateDate,
 	)
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return 0, err
 	}
-	if id != 0 {
-		return id, nil
+	if existing.ID != nil {
+		return *existing.ID, nil
+	}
+	if existing.Skip {
+		return 0, ErrSkipDate
 	}
 	transaction, err := conn.Begin(ctx)
 	if err != nil {
@@ -99,28 +142,45 @@ func (repo *PgSessionRepo) CreateSession(ctx context.Context, s Session) (uint64
 	}
 
 	defer transaction.Rollback(ctx)
+	facilitator, err := pickFacilitator(ctx, transaction, s)
+	if err != nil {
+		return 0, err
+	}
 	// Session is new, create
+	var id uint64
 	if err = pgxscan.Get(
 		ctx,
 		transaction,
 		&id,
-		"INSERT INTO sessions (group_id, create_date, shuffle_seed) VALUES ($1, $2, $3) RETURNING id",
+		`INSERT INTO sessions (group_id, create_date, shuffle_seed, facilitator_email)
+		VALUES ($1, $2, $3, NULLIF($4, '')) RETURNING id`,
 		s.GroupID,
 		s.CreateDate,
 		s.ShuffleSeed,
+		facilitator,
 	); err != nil {
 		return 0, err
 	}
 
+	// Carry over from the last real standup: one held on a day that isn't
+	// skipped, where someone said what they would do. Sessions nobody used
+	// would otherwise wipe everyone's items.
 	if _, err = transaction.Exec(
 		ctx,
 		`
 		WITH prev_session AS (
-			SELECT id 
-			FROM sessions 
-			WHERE id != $1
-			AND group_id = $2
-			ORDER BY id DESC 
+			SELECT s.id
+			FROM sessions s
+			JOIN groups g ON g.id = s.group_id
+			WHERE s.id != $1
+			AND s.group_id = $2
+			AND s.create_date < $3
+			AND NOT is_skip_date(g.id, (s.create_date at time zone g.timezone)::date)
+			AND EXISTS (
+				SELECT 1 FROM user_submissions u
+				WHERE u.session_id = s.id AND cardinality(u.today) > 0
+			)
+			ORDER BY s.create_date DESC
 			LIMIT 1
 		)
 		INSERT INTO user_submissions (user_id, session_id, yesterday, today, blockers)
@@ -131,6 +191,7 @@ func (repo *PgSessionRepo) CreateSession(ctx context.Context, s Session) (uint64
 		`,
 		id,
 		s.GroupID,
+		s.CreateDate,
 	); err != nil {
 		return id, err
 	}
@@ -139,6 +200,68 @@ func (repo *PgSessionRepo) CreateSession(ctx context.Context, s Session) (uint64
 	return id, err
 }
 
+// Applies the group's facilitator policy to the members who can run a session
+func pickFacilitator(ctx context.Context, tx pgx.Tx, s Session) (string, error) {
+	var row struct {
+		Policy     group.FacilitatorPolicy `db:"facilitator_policy"`
+		Fixed      *string                 `db:"facilitator_email"`
+		Previous   *string                 `db:"previous"`
+		Candidates []string                `db:"candidates"`
+	}
+	err := pgxscan.Get(
+		ctx,
+		tx,
+		&row,
+		`SELECT g.facilitator_policy, g.facilitator_email,
+			(SELECT s.facilitator_email FROM sessions s
+			WHERE s.group_id = g.id AND s.facilitator_email IS NOT NULL
+			ORDER BY s.create_date DESC LIMIT 1) AS previous,
+			ARRAY(
+				SELECT m.email FROM group_members m
+				WHERE m.group_id = g.id AND m.role = ANY($2)
+				ORDER BY m.position, m.join_date
+			) AS candidates
+		FROM groups g WHERE g.id = $1`,
+		s.GroupID,
+		group.RolesWith(group.PermRunSession),
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	var previous, fixed string
+	if row.Previous != nil {
+		previous = *row.Previous
+	}
+	if row.Fixed != nil {
+		fixed = *row.Fixed
+	}
+	return row.Policy.Pick(row.Candidates, previous, fixed, s.ShuffleSeed), nil
+}
+
+// Hands the session to another facilitator, who must be able to run sessions
+// in the group.
+func (repo *PgSessionRepo) SetFacilitator(ctx context.Context, id uint64, email string) error {
+	tag, err := repo.db.Exec(
+		ctx,
+		`UPDATE sessions s SET facilitator_email = m.email
+		FROM group_members m
+		WHERE s.id = $1 AND m.group_id = s.group_id AND m.email = lower($2) AND m.role = ANY($3)`,
+		id,
+		email,
+		group.RolesWith(group.PermRunSession),
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidFacilitator
+	}
+	return nil
+}
+
 func (repo *PgSessionRepo) UpdateShuffle(ctx context.Context, id uint64, seed uint16) error {
 	conn, err := repo.db.Acquire(ctx)
 	defer conn.Release()
@@ -154,6 +277,67 @@ func (repo *PgSessionRepo) UpdateShuffle(ctx context.Context, id uint64, seed ui
 	return err
 }
 
+// The session is locked while State.Apply decides the new state, so
+// concurrent transitions see each other's result.
+func (repo *PgSessionRepo) TransitionSession(
+	ctx context.Context,
+	id uint64,
+	a Action,
+) (Session, error) {
+	conn, err := repo.db.Acquire(ctx)
+	if err != nil {
+		return Session{}, err
+	}
+	defer conn.Release()
+	transaction, err := conn.Begin(ctx)
+	if err != nil {
+		return Session{}, err
+	}
+	defer transaction.Rollback(ctx)
+	var current State
+	err = pgxscan.Get(
+		ctx,
+		transaction,
+		&current,
+		"SELECT state FROM sessions WHERE id = $1 FOR UPDATE",
+		id,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return Session{}, ErrSessionNotFound
+		}
+		return Session{}, err
+	}
+	to, err := current.Apply(a)
+	if err != nil {
+		return Session{}, err
+	}
+	var sess Session
+	err = pgxscan.Get(
+		ctx,
+		transaction,
+		&sess,
+		`UPDATE sessions SET
+			state = $2,
+			started_at = CASE WHEN $3 = 'start' THEN NOW() ELSE started_at END,
+			closed_at = CASE WHEN $3 = 'close' THEN NOW() ELSE closed_at END,
+			reopened_at = CASE WHEN $3 = 'reopen' THEN NOW() ELSE reopened_at END,
+			locked_at = CASE WHEN $3 = 'lock' THEN NOW() ELSE locked_at END
+		WHERE id = $1
+		RETURNING *`,
+		id,
+		to,
+		a,
+	)
+	if err != nil {
+		return Session{}, err
+	}
+	if err = transaction.Commit(ctx); err != nil {
+		return Session{}, err
+	}
+	return sess, nil
+}
+
 func (repo *PgSessionRepo) UserInSession(
 	ctx context.Context,
 	id uint64,
@@ -172,12 +356,15 @@ func (repo *PgSessionRepo) UserInSession(
 		`
 			SELECT true
 			FROM sessions s
-			JOIN groups g ON s.group_id = g.id
+			JOIN group_members m ON m.group_id = s.group_id
 			WHERE s.id = $1
-			AND $2 = ANY(g.allowed_emails);
+			AND m.email = lower($2);
 		`,
 		id,
 		email,
 	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
 	return exists, err
 }
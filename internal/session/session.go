@@ -8,10 +8,16 @@ import (
 )
 
 type Session struct {
-	ID          uint64           `json:"id"           db:"id"`
-	GroupID     uint64           `json:"group_id"     db:"group_id"`
-	CreateDate  types.CustomTime `json:"create_date"  db:"create_date"`
-	ShuffleSeed uint16           `json:"shuffle_seed" db:"shuffle_seed"`
+	ID          uint64            `json:"id"           db:"id"`
+	GroupID     uint64            `json:"group_id"     db:"group_id"`
+	CreateDate  types.CustomTime  `json:"create_date"  db:"create_date"`
+	ShuffleSeed uint16            `json:"shuffle_seed" db:"shuffle_seed"`
+	State       State             `json:"state"        db:"state"`
+	StartedAt   *types.CustomTime `json:"started_at"   db:"started_at"`
+	ClosedAt    *types.CustomTime `json:"closed_at"    db:"closed_at"`
+	ReopenedAt  *types.CustomTime `json:"reopened_at"  db:"reopened_at"`
+	LockedAt    *types.CustomTime `json:"locked_at"    db:"locked_at"`
+	Facilitator *string           `json:"facilitator"  db:"facilitator_email"`
 }
 
 func NewSession(groupID uint64) Session {
@@ -19,9 +25,27 @@ func NewSession(groupID uint64) Session {
 		GroupID:     groupID,
 		CreateDate:  types.CustomTime(time.Now()),
 		ShuffleSeed: NewSeed(),
+		State:       StateOpen,
 	}
 }
 
 func NewSeed() uint16 {
 	return uint16(rand.UintN(32767))
 }
+
+// Permutes n items into the speaking order for seed. Everyone who sorts the
+// items by user ID first gets the same order.
+func Shuffle(seed uint16, n int, swap func(i, j int)) {
+	r := rand.New(rand.NewPCG(uint64(seed), uint64(seed)))
+	r.Shuffle(n, swap)
+}
+
+// Filters for listing a group's sessions, newest first. From and To are
+// inclusive YYYY-MM-DD dates in the group's timezone and BeforeID continues a
+// listing after the last session of the previous page.
+type ListOptions struct {
+	From     string
+	To       string
+	BeforeID uint64
+	Limit    int
+}
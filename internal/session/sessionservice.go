@@ -6,14 +6,21 @@ import (
 )
 
 var (
-	ErrSessionNotFound = errors.New("session not found")
-	ErrSessionExists   = errors.New("session already exists")
+	ErrSessionNotFound    = errors.New("session not found")
+	ErrSessionExists      = errors.New("session already exists")
+	ErrSkipDate           = errors.New("group does not meet on this day")
+	ErrInvalidFacilitator = errors.New("facilitator must be a member who can run sessions")
 )
 
 type SessionService interface {
 	GetSession(ctx context.Context, id uint64) (Session, error)
 	GetSessionByGroupID(ctx context.Context, groupID uint64) (Session, error)
+	ListSessions(ctx context.Context, groupID uint64, opts ListOptions) ([]Session, error)
 	CreateSession(ctx context.Context, s Session) (uint64, error)
 	UpdateShuffle(ctx context.Context, id uint64, seed uint16) error
+	SetFacilitator(ctx context.Context, id uint64, email string) error
+	// Applies the action and stamps its time. Returns ErrInvalidTransition when
+	// the session's current state doesn't allow it.
+	TransitionSession(ctx context.Context, id uint64, a Action) (Session, error)
 	UserInSession(ctx context.Context, id uint64, email string) (bool, error)
 }
@@ -0,0 +1,55 @@
+package session
+
+import (
+	"errors"
+	"slices"
+)
+
+type State string
+
+const (
+	// Created, standup hasn't started
+	StateOpen       State = "open"
+	StateInProgress State = "in_progress"
+	// Finished. Admins can reopen a closed session.
+	StateClosed State = "closed"
+	// Finished for good
+	StateLocked State = "locked"
+)
+
+type Action string
+
+const (
+	ActionStart  Action = "start"
+	ActionClose  Action = "close"
+	ActionReopen Action = "reopen"
+	ActionLock   Action = "lock"
+)
+
+var ErrInvalidTransition = errors.New("session cannot make that transition")
+
+type transition struct {
+	from []State
+	to   State
+}
+
+var transitions = map[Action]transition{
+	ActionStart:  {from: []State{StateOpen}, to: StateInProgress},
+	ActionClose:  {from: []State{StateOpen, StateInProgress}, to: StateClosed},
+	ActionReopen: {from: []State{StateClosed}, to: StateInProgress},
+	ActionLock:   {from: []State{StateClosed}, to: StateLocked},
+}
+
+// Returns the state the action moves a session in st to
+func (st State) Apply(a Action) (State, error) {
+	t, ok := transitions[a]
+	if !ok || !slices.Contains(t.from, st) {
+		return "", ErrInvalidTransition
+	}
+	return t.to, nil
+}
+
+// Submissions can only be written while the standup is open or running
+func (st State) AcceptsSubmissions() bool {
+	return st == StateOpen || st == StateInProgress
+}
@@ -0,0 +1,27 @@
+package session
+
+import "testing"
+
+func TestStateApply(t *testing.T) {
+	tests := []struct {
+		from   State
+		action Action
+		want   State
+		ok     bool
+	}{
+		{StateOpen, ActionStart, StateInProgress, true},
+		{StateOpen, ActionClose, StateClosed, true},
+		{StateInProgress, ActionClose, StateClosed, true},
+		{StateClosed, ActionReopen, StateInProgress, true},
+		{StateClosed, ActionLock, StateLocked, true},
+		{StateInProgress, ActionStart, "", false},
+		{StateLocked, ActionReopen, "", false},
+		{StateOpen, Action("explode"), "", false},
+	}
+	for _, tt := range tests {
+		got, err := tt.from.Apply(tt.action)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("%s.Apply(%s) = %q, %v", tt.from, tt.action, got, err)
+		}
+	}
+}
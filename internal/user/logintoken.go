@@ -0,0 +1,30 @@
+package user
+
+import (
+	"time"
+
+	"github.com/dilithaw123/broccoli-backend/internal/types"
+)
+
+// A new link is refused while the email was sent one in the last minute, or
+// while its IP has asked for too many recently
+const (
+	loginTokenEmailInterval = time.Minute
+	loginTokenIPLimit       = 10
+	loginTokenIPWindow      = time.Hour
+)
+
+// A LoginToken records an emailed magic link so it can only be used once.
+// The link itself is a signed JWT whose jti matches JTI.
+type LoginToken struct {
+	ID    uint64 `db:"id"`
+	JTI   string `db:"jti"`
+	Email string `db:"email"`
+	// Address the link was requested from
+	IP        string           `db:"ip"`
+	ExpiresAt types.CustomTime `db:"expires_at"`
+}
+
+func NewJTI() string {
+	return randomToken(16)
+}
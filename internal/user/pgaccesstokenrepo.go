@@ -0,0 +1,108 @@
+package user
+
+import (
+	"context"
+	"errors"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PgAccessTokenRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPgAccessTokenRepo(db *pgxpool.Pool) *PgAccessTokenRepo {
+	return &PgAccessTokenRepo{db: db}
+}
+
+func (repo *PgAccessTokenRepo) CreatePersonalAccessToken(
+	ctx context.Context,
+	t PersonalAccessToken,
+) (PersonalAccessToken, error) {
+	err := pgxscan.Get(
+		ctx,
+		repo.db,
+		&t.ID,
+		`INSERT INTO personal_access_tokens
+		(user_id, name, token_hash, scopes, create_date, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		t.UserID,
+		t.Name,
+		t.TokenHash,
+		t.Scopes,
+		t.CreateDate,
+		t.ExpiresAt,
+	)
+	if err != nil {
+		return PersonalAccessToken{}, err
+	}
+	return t, nil
+}
+
+func (repo *PgAccessTokenRepo) GetPersonalAccessTokens(
+	ctx context.Context,
+	userID uint64,
+) ([]PersonalAccessToken, error) {
+	tokens := []PersonalAccessToken{}
+	err := pgxscan.Select(
+		ctx,
+		repo.db,
+		&tokens,
+		`SELECT id, user_id, name, token_hash, scopes, create_date, last_used, expires_at
+		FROM personal_access_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL
+		ORDER BY create_date DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+func (repo *PgAccessTokenRepo) RevokePersonalAccessToken(
+	ctx context.Context,
+	userID, id uint64,
+) error {
+	tag, err := repo.db.Exec(
+		ctx,
+		`UPDATE personal_access_tokens SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL`,
+		id,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAccessTokenNotFound
+	}
+	return nil
+}
+
+func (repo *PgAccessTokenRepo) UsePersonalAccessToken(
+	ctx context.Context,
+	hash string,
+) (PersonalAccessToken, error) {
+	var t PersonalAccessToken
+	err := pgxscan.Get(
+		ctx,
+		repo.db,
+		&t,
+		`UPDATE personal_access_tokens SET last_used = NOW()
+		WHERE token_hash = $1
+		AND revoked_at IS NULL
+		AND (expires_at IS NULL OR expires_at > NOW())
+		RETURNING id, user_id, name, token_hash, scopes, create_date, last_used, expires_at`,
+		hash,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return PersonalAccessToken{}, ErrAccessTokenNotFound
+		}
+		return PersonalAccessToken{}, err
+	}
+	return t, nil
+}
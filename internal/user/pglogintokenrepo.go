@@ -0,0 +1,68 @@
+package user
+
+import (
+	"context"
+	"errors"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PgLoginTokenRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPgLoginTokenRepo(db *pgxpool.Pool) *PgLoginTokenRepo {
+	return &PgLoginTokenRepo{db: db}
+}
+
+func (repo *PgLoginTokenRepo) CreateLoginToken(ctx context.Context, t LoginToken) error {
+	tag, err := repo.db.Exec(
+		ctx,
+		`INSERT INTO login_tokens (jti, email, ip, expires_at)
+		SELECT $1, $2, $3, $4
+		WHERE NOT EXISTS (
+			SELECT 1 FROM login_tokens
+			WHERE email = $2 AND created_at > NOW() - $5 * INTERVAL '1 second'
+		)
+		AND (
+			SELECT COUNT(*) FROM login_tokens
+			WHERE ip = $3 AND created_at > NOW() - $6 * INTERVAL '1 second'
+		) < $7`,
+		t.JTI,
+		t.Email,
+		t.IP,
+		t.ExpiresAt,
+		loginTokenEmailInterval.Seconds(),
+		loginTokenIPWindow.Seconds(),
+		loginTokenIPLimit,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLoginTokenThrottled
+	}
+	return nil
+}
+
+func (repo *PgLoginTokenRepo) ConsumeLoginToken(ctx context.Context, jti string) (LoginToken, error) {
+	var t LoginToken
+	err := pgxscan.Get(
+		ctx,
+		repo.db,
+		&t,
+		`UPDATE login_tokens SET used_at = NOW()
+		WHERE jti = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING id, jti, email, expires_at`,
+		jti,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return LoginToken{}, ErrLoginTokenInvalid
+		}
+		return LoginToken{}, err
+	}
+	return t, nil
+}
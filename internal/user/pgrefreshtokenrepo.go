@@ -0,0 +1,224 @@
+package user
+
+import (
+	"context"
+	"errors"
+
+	"github.com/georgysavva/scany/v2/pgxscan"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PgRefreshTokenRepo struct {
+	db *pgxpool.Pool
+}
+
+func NewPgRefreshTokenRepo(db *pgxpool.Pool) *PgRefreshTokenRepo {
+	return &PgRefreshTokenRepo{db: db}
+}
+
+func (repo *PgRefreshTokenRepo) CreateRefreshToken(
+	ctx context.Context,
+	t RefreshToken,
+) (RefreshToken, error) {
+	conn, err := repo.db.Acquire(ctx)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	defer conn.Release()
+	err = pgxscan.Get(
+		ctx,
+		conn,
+		&t.ID,
+		`INSERT INTO refresh_tokens
+		(user_id, family_id, token_hash, user_agent, ip, create_date, last_used, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		t.UserID,
+		t.FamilyID,
+		t.TokenHash,
+		t.UserAgent,
+		t.IP,
+		t.CreateDate,
+		t.LastUsed,
+		t.ExpiresAt,
+	)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	return t, nil
+}
+
+// The user and family of next are taken from the token being rotated.
+func (repo *PgRefreshTokenRepo) RotateRefreshToken(
+	ctx context.Context,
+	hash string,
+	next RefreshToken,
+) (RefreshToken, error) {
+	conn, err := repo.db.Acquire(ctx)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	defer conn.Release()
+	transaction, err := conn.Begin(ctx)
+	if err != nil {
+		return RefreshToken{}, err
+	}
+	defer transaction.Rollback(ctx)
+
+	var current struct {
+		ID       uint64 `db:"id"`
+		UserID   uint64 `db:"user_id"`
+		FamilyID string `db:"family_id"`
+		Revoked  bool   `db:"revoked"`
+		Expired  bool   `db:"expired"`
+	}
+	err = pgxscan.Get(
+		ctx,
+		transaction,
+		&current,
+		`SELECT id, user_id, family_id,
+			revoked_at IS NOT NULL AS revoked,
+			expires_at <= NOW() AS expired
+		FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`,
+		hash,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return RefreshToken{}, ErrRefreshTokenNotFound
+		}
+		return RefreshToken{}, err
+	}
+	if current.Revoked {
+		// Someone is replaying an old token, so neither copy can be trusted.
+		if _, err = transaction.Exec(
+			ctx,
+			"UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL",
+			current.FamilyID,
+		); err != nil {
+			return RefreshToken{}, err
+		}
+		if err = transaction.Commit(ctx); err != nil {
+			return RefreshToken{}, err
+		}
+		return RefreshToken{}, ErrRefreshTokenReused
+	}
+	if current.Expired {
+		return RefreshToken{}, ErrRefreshTokenExpired
+	}
+
+	next.UserID = current.UserID
+	next.FamilyID = current.FamilyID
+	if err = pgxscan.Get(
+		ctx,
+		transaction,
+		&next.ID,
+		`INSERT INTO refresh_tokens
+		(user_id, family_id, token_hash, user_agent, ip, create_date, last_used, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		next.UserID,
+		next.FamilyID,
+		next.TokenHash,
+		next.UserAgent,
+		next.IP,
+		next.CreateDate,
+		next.LastUsed,
+		next.ExpiresAt,
+	); err != nil {
+		return RefreshToken{}, err
+	}
+	if _, err = transaction.Exec(
+		ctx,
+		"UPDATE refresh_tokens SET revoked_at = NOW(), replaced_by = $2 WHERE id = $1",
+		current.ID,
+		next.ID,
+	); err != nil {
+		return RefreshToken{}, err
+	}
+	if err = transaction.Commit(ctx); err != nil {
+		return RefreshToken{}, err
+	}
+	return next, nil
+}
+
+func (repo *PgRefreshTokenRepo) RevokeRefreshToken(ctx context.Context, hash string) error {
+	tag, err := repo.db.Exec(
+		ctx,
+		"UPDATE refresh_tokens SET revoked_at = NOW() WHERE token_hash = $1 AND revoked_at IS NULL",
+		hash,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRefreshTokenNotFound
+	}
+	return nil
+}
+
+func (repo *PgRefreshTokenRepo) RevokeRefreshTokenFamily(
+	ctx context.Context,
+	familyID string,
+) error {
+	_, err := repo.db.Exec(
+		ctx,
+		"UPDATE refresh_tokens SET revoked_at = NOW() WHERE family_id = $1 AND revoked_at IS NULL",
+		familyID,
+	)
+	return err
+}
+
+func (repo *PgRefreshTokenRepo) RevokeRefreshTokenByID(
+	ctx context.Context,
+	userID, id uint64,
+) error {
+	tag, err := repo.db.Exec(
+		ctx,
+		`UPDATE refresh_tokens SET revoked_at = NOW()
+		WHERE family_id = (SELECT family_id FROM refresh_tokens WHERE id = $1 AND user_id = $2)
+		AND revoked_at IS NULL`,
+		id,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrRefreshTokenNotFound
+	}
+	return nil
+}
+
+func (repo *PgRefreshTokenRepo) RevokeAllRefreshTokens(ctx context.Context, userID uint64) error {
+	_, err := repo.db.Exec(
+		ctx,
+		"UPDATE refresh_tokens SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL",
+		userID,
+	)
+	return err
+}
+
+func (repo *PgRefreshTokenRepo) GetActiveRefreshTokens(
+	ctx context.Context,
+	userID uint64,
+) ([]RefreshToken, error) {
+	tokens := []RefreshToken{}
+	conn, err := repo.db.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+	err = pgxscan.Select(
+		ctx,
+		conn,
+		&tokens,
+		`SELECT id, user_id, family_id, token_hash, user_agent, ip, create_date, last_used, expires_at
+		FROM refresh_tokens
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used DESC`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
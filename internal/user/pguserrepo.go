@@ -3,8 +3,8 @@ package user
 import (
 	"context"
 	"errors"
-	"math/rand/v2"
 
+	"github.com/dilithaw123/broccoli-backend/internal/session"
 	"github.com/georgysavva/scany/v2/pgxscan"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -135,7 +135,10 @@ func (repo *PgUserRepo) GetAllUserSubmissionsForSession(
 		ctx,
 		conn,
 		&us,
-		`SELECT us.*, u.name FROM user_submissions us JOIN users u ON us.user_id = u.id WHERE us.session_id = $1`,
+		// Sorted before shuffling so every caller gets the same order for a seed
+		`SELECT us.*, u.name FROM user_submissions us JOIN users u ON us.user_id = u.id
+		WHERE us.session_id = $1
+		ORDER BY us.user_id`,
 		sessionId,
 	)
 	if err != nil {
@@ -145,7 +148,7 @@ func (repo *PgUserRepo) GetAllUserSubmissionsForSession(
 		return nil, err
 	}
 
-	var shuffle_seed uint64
+	var shuffle_seed uint16
 	err = pgxscan.Get(
 		ctx,
 		conn,
@@ -156,34 +159,45 @@ func (repo *PgUserRepo) GetAllUserSubmissionsForSession(
 	if err != nil {
 		return us, err
 	}
-	src := rand.NewPCG(shuffle_seed, shuffle_seed)
-	r := rand.New(src)
-	r.Shuffle(len(us), func(i, j int) {
+	session.Shuffle(shuffle_seed, len(us), func(i, j int) {
 		us[i], us[j] = us[j], us[i]
 	})
 	return us, nil
 }
 
+// Sessions that are closed or locked take no writes and return
+// ErrSubmissionsLocked.
 func (repo *PgUserRepo) CreateUpdateUserSubmission(ctx context.Context, us UserSubmission) error {
 	conn, err := repo.db.Acquire(ctx)
 	if err != nil {
 		return err
 	}
 	defer conn.Release()
-	_, err = conn.Exec(
+	tag, err := conn.Exec(
 		ctx,
 		`MERGE INTO user_submissions us
-		USING (SELECT $1::BIGINT as user_id, $2::BIGINT as session_id) s
+		USING (
+			SELECT $1::BIGINT as user_id, id as session_id FROM sessions
+			WHERE id = $2 AND state IN ('open', 'in_progress')
+		) s
 		ON us.user_id = s.user_id AND us.session_id = s.session_id
 		WHEN MATCHED THEN
-			UPDATE SET yesterday = $3, today = $4, blockers = $5
+			UPDATE SET yesterday = $3, today = $4, blockers = $5, edited_by = $6
 		WHEN NOT MATCHED THEN
-			INSERT (user_id, session_id, yesterday, today, blockers) VALUES (s.user_id, s.session_id, $3, $4, $5)`,
+			INSERT (user_id, session_id, yesterday, today, blockers, edited_by)
+			VALUES (s.user_id, s.session_id, $3, $4, $5, $6)`,
 		us.UserId,
 		us.SessionId,
 		us.Yesterday,
 		us.Today,
 		us.Blockers,
+		us.EditedBy,
 	)
-	return err
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrSubmissionsLocked
+	}
+	return nil
 }
@@ -0,0 +1,63 @@
+package user
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"time"
+
+	"github.com/dilithaw123/broccoli-backend/internal/types"
+)
+
+// A RefreshToken is one device's login. Only the SHA-256 hash of the token
+// is stored; every rotation creates a new row in the same family.
+type RefreshToken struct {
+	ID         uint64           `json:"id"          db:"id"`
+	UserID     uint64           `json:"user_id"     db:"user_id"`
+	FamilyID   string           `json:"-"           db:"family_id"`
+	TokenHash  string           `json:"-"           db:"token_hash"`
+	UserAgent  string           `json:"user_agent"  db:"user_agent"`
+	IP         string           `json:"ip"          db:"ip"`
+	CreateDate types.CustomTime `json:"create_date" db:"create_date"`
+	LastUsed   types.CustomTime `json:"last_used"   db:"last_used"`
+	ExpiresAt  types.CustomTime `json:"expires_at"  db:"expires_at"`
+}
+
+// NewRefreshToken returns the plaintext token to hand to the client and the
+// record to persist for it. An empty familyID starts a new family.
+func NewRefreshToken(
+	userID uint64,
+	familyID, userAgent, ip string,
+	ttl time.Duration,
+) (string, RefreshToken) {
+	token := randomToken(32)
+	if familyID == "" {
+		familyID = randomToken(16)
+	}
+	now := time.Now().UTC()
+	return token, RefreshToken{
+		UserID:     userID,
+		FamilyID:   familyID,
+		TokenHash:  HashToken(token),
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreateDate: types.CustomTime(now),
+		LastUsed:   types.CustomTime(now),
+		ExpiresAt:  types.CustomTime(now.Add(ttl)),
+	}
+}
+
+// HashToken returns the hex encoded SHA-256 of an opaque token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomToken(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
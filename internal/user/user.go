@@ -11,13 +11,15 @@ type User struct {
 	Email string `json:"email" db:"email"`
 }
 
+// EditedBy is only set when someone other than the owner last wrote the submission
 type UserSubmission struct {
-	ID        uint64   `json:"id"         db:"id"`
-	UserId    uint64   `json:"user_id"    db:"user_id"`
-	SessionId uint64   `json:"session_id" db:"session_id"`
-	Yesterday []string `json:"yesterday"  db:"yesterday"`
-	Today     []string `json:"today"      db:"today"`
-	Blockers  []string `json:"blockers"   db:"blockers"`
+	ID        uint64   `json:"id"                  db:"id"`
+	UserId    uint64   `json:"user_id"             db:"user_id"`
+	SessionId uint64   `json:"session_id"          db:"session_id"`
+	Yesterday []string `json:"yesterday"           db:"yesterday"`
+	Today     []string `json:"today"               db:"today"`
+	Blockers  []string `json:"blockers"            db:"blockers"`
+	EditedBy  *uint64  `json:"edited_by,omitempty" db:"edited_by"`
 }
 
 type DBUserSubmission struct {
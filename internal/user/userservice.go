@@ -9,6 +9,13 @@ var (
 	ErrUserAlreadyExists        = errors.New("user with email already exists")
 	ErrUserNotFound             = errors.New("user not found")
 	ErrorUserSubmissionNotFound = errors.New("user submission not found")
+	ErrRefreshTokenNotFound     = errors.New("refresh token not found")
+	ErrRefreshTokenExpired      = errors.New("refresh token expired")
+	ErrRefreshTokenReused       = errors.New("refresh token reused")
+	ErrLoginTokenInvalid        = errors.New("login token invalid or already used")
+	ErrLoginTokenThrottled      = errors.New("too many login links requested")
+	ErrAccessTokenNotFound      = errors.New("access token not found")
+	ErrSubmissionsLocked        = errors.New("session is closed to submissions")
 )
 
 type UserService interface {
@@ -22,3 +29,38 @@ type UserService interface {
 	) ([]DBUserSubmission, error)
 	CreateUpdateUserSubmission(ctx context.Context, us UserSubmission) error
 }
+
+type RefreshTokenService interface {
+	CreateRefreshToken(ctx context.Context, t RefreshToken) (RefreshToken, error)
+	// Replaces the token with the given hash by next. Presenting a token that
+	// was already rotated or revoked revokes its whole family and returns
+	// ErrRefreshTokenReused.
+	RotateRefreshToken(ctx context.Context, hash string, next RefreshToken) (RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, hash string) error
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	// Revokes the device with the given token ID if it belongs to the user
+	RevokeRefreshTokenByID(ctx context.Context, userID, id uint64) error
+	RevokeAllRefreshTokens(ctx context.Context, userID uint64) error
+	// Lists the live token of every logged in device
+	GetActiveRefreshTokens(ctx context.Context, userID uint64) ([]RefreshToken, error)
+}
+
+type LoginTokenService interface {
+	// Fails with ErrLoginTokenThrottled when the email or IP has asked for
+	// too many links recently
+	CreateLoginToken(ctx context.Context, t LoginToken) error
+	// Marks the token as used. Fails with ErrLoginTokenInvalid if it was
+	// already used, has expired or never existed.
+	ConsumeLoginToken(ctx context.Context, jti string) (LoginToken, error)
+}
+
+type PersonalAccessTokenService interface {
+	CreatePersonalAccessToken(
+		ctx context.Context,
+		t PersonalAccessToken,
+	) (PersonalAccessToken, error)
+	GetPersonalAccessTokens(ctx context.Context, userID uint64) ([]PersonalAccessToken, error)
+	RevokePersonalAccessToken(ctx context.Context, userID, id uint64) error
+	// Looks up a live token by hash and records that it was used
+	UsePersonalAccessToken(ctx context.Context, hash string) (PersonalAccessToken, error)
+}
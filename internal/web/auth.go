@@ -1,60 +1,52 @@
 package web
 
 import (
-	"math/rand"
+	"strconv"
 	"time"
 
+	"github.com/dilithaw123/broccoli-backend/internal/user"
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const accessTokenTTL = time.Hour
+
 type CustomClaims struct {
 	jwt.RegisteredClaims
 	Email string `json:"email"`
 }
 
-var chars []rune = []rune("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz")
-
-func RandStringRunes(n int) string {
-	length := len(chars)
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = chars[rand.Intn(length)]
-	}
-	return string(b)
-}
-
-func GenerateRefreshToken() string {
-	return RandStringRunes(32)
-}
-
-func GenerateAccessToken(email, secret string) string {
-	expirationTime := time.Now().Add(time.Hour).UTC()
+func GenerateAccessToken(u user.User, keys *Keyring, issuer, audience string) (string, error) {
+	now := time.Now().UTC()
 	claims := &CustomClaims{
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			Subject:   strconv.FormatUint(u.ID, 10),
+			Issuer:    issuer,
+			Audience:  jwt.ClaimStrings{audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
 		},
-		Email: email,
+		Email: u.Email,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, _ := token.SignedString([]byte(secret))
-	return tokenString
+	return keys.Sign(claims)
 }
 
-func parseAccessToken(tokenString string, secret string) (*jwt.Token, error) {
+func parseAccessToken(tokenString string, keys *Keyring) (*jwt.Token, error) {
 	return jwt.ParseWithClaims(
 		tokenString,
 		&CustomClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return []byte(secret), nil
-		},
+		keys.keyFunc,
+		jwt.WithoutClaimsValidation(),
 	)
 }
 
-func validateToken(token *jwt.Token) bool {
-	validator := jwt.NewValidator()
+func validateToken(token *jwt.Token, issuer, audience string) bool {
+	validator := jwt.NewValidator(
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audience),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
 	err := validator.Validate(token.Claims)
 	if err != nil {
 		return false
@@ -62,10 +54,23 @@ func validateToken(token *jwt.Token) bool {
 	return token.Valid
 }
 
-func ParseAndValidateToken(tokenString string, secret string) (*jwt.Token, bool) {
-	token, err := parseAccessToken(tokenString, secret)
+func ParseAndValidateToken(
+	tokenString string,
+	keys *Keyring,
+	issuer, audience string,
+) (*jwt.Token, bool) {
+	token, err := parseAccessToken(tokenString, keys)
 	if err != nil {
 		return token, false
 	}
-	return token, validateToken(token)
+	return token, validateToken(token, issuer, audience)
+}
+
+// Returns the authenticated user carried by valid claims
+func (c *CustomClaims) Principal() (Principal, bool) {
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil || id == 0 || c.Email == "" {
+		return Principal{}, false
+	}
+	return Principal{ID: id, Email: c.Email}, true
 }
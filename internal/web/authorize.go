@@ -0,0 +1,103 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/dilithaw123/broccoli-backend/internal/group"
+	"github.com/dilithaw123/broccoli-backend/internal/session"
+)
+
+// Checks that the caller's role in the group grants perm. When it doesn't, the
+// error response has already been written and ok is false.
+func (s *Server) authorizeGroup(
+	w http.ResponseWriter,
+	r *http.Request,
+	groupID uint64,
+	perm group.Permission,
+) (group.Role, bool) {
+	p := principal(r)
+	role, err := s.groupService.GetMemberRole(r.Context(), groupID, p.Email)
+	switch {
+	case errors.Is(err, group.ErrGroupNotFound):
+		http.Error(w, "group not found", http.StatusNotFound)
+		return "", false
+	case errors.Is(err, group.ErrNotMember):
+		s.logger.Info("User not in group", "groupId", groupID, "email", p.Email)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return "", false
+	case err != nil:
+		s.logger.Error("Failed to get member role", "error", err, "groupId", groupID)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return "", false
+	case !role.Can(perm):
+		s.logger.Info(
+			"Permission denied",
+			"groupId", groupID,
+			"email", p.Email,
+			"role", role,
+			"permission", perm,
+		)
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return role, false
+	}
+	return role, true
+}
+
+// Like authorizeGroup for the group the session belongs to
+func (s *Server) authorizeSession(
+	w http.ResponseWriter,
+	r *http.Request,
+	sessionID uint64,
+	perm group.Permission,
+) (session.Session, group.Role, bool) {
+	sess, err := s.sessionService.GetSession(r.Context(), sessionID)
+	if err != nil {
+		if errors.Is(err, session.ErrSessionNotFound) {
+			http.Error(w, "session not found", http.StatusNotFound)
+			return session.Session{}, "", false
+		}
+		s.logger.Error("Failed to get session", "error", err, "sessionId", sessionID)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return session.Session{}, "", false
+	}
+	role, ok := s.authorizeGroup(w, r, sess.GroupID, perm)
+	return sess, role, ok
+}
+
+// Session controls belong to the session's facilitator, with admins able to
+// step in. Sessions without a facilitator fall back to anyone who can run them.
+func (s *Server) authorizeFacilitator(
+	w http.ResponseWriter,
+	r *http.Request,
+	sessionID uint64,
+) (session.Session, group.Role, bool) {
+	sess, role, ok := s.authorizeSession(w, r, sessionID, group.PermRunSession)
+	if !ok {
+		return sess, role, false
+	}
+	if !canFacilitate(sess, principal(r).Email, role) {
+		http.Error(w, "only the facilitator can do that", http.StatusForbidden)
+		return sess, role, false
+	}
+	return sess, role, true
+}
+
+// Unlike the other session controls, editing someone's submission is never
+// open to everyone, so it needs a named facilitator.
+func canEditOthers(sess session.Session, email string, role group.Role) bool {
+	if role.Can(group.PermEditOthers) {
+		return true
+	}
+	return role.Can(group.PermRunSession) && sess.Facilitator != nil && *sess.Facilitator == email
+}
+
+func canFacilitate(sess session.Session, email string, role group.Role) bool {
+	if role.Can(group.PermManageSessions) {
+		return true
+	}
+	if !role.Can(group.PermRunSession) {
+		return false
+	}
+	return sess.Facilitator == nil || *sess.Facilitator == email
+}
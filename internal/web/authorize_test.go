@@ -0,0 +1,86 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/dilithaw123/broccoli-backend/internal/group"
+	"github.com/dilithaw123/broccoli-backend/internal/session"
+	"github.com/dilithaw123/broccoli-backend/internal/user"
+)
+
+func TestCanEditOthers(t *testing.T) {
+	facilitator := "lead@example.com"
+	led := session.Session{Facilitator: &facilitator}
+	tests := []struct {
+		name  string
+		sess  session.Session
+		email string
+		role  group.Role
+		want  bool
+	}{
+		{"admin", session.Session{}, "admin@example.com", group.RoleAdmin, true},
+		{"facilitator", led, facilitator, group.RoleMember, true},
+		{"other member", led, "someone@example.com", group.RoleMember, false},
+		{"no facilitator", session.Session{}, "someone@example.com", group.RoleMember, false},
+		{"viewer facilitator", led, facilitator, group.RoleViewer, false},
+	}
+	for _, tt := range tests {
+		if got := canEditOthers(tt.sess, tt.email, tt.role); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestClientCanWrite(t *testing.T) {
+	if !(client{}).canWrite() {
+		t.Error("Interactive logins should run session commands")
+	}
+	if (client{scopes: []string{user.ScopeSessionsRead}}).canWrite() {
+		t.Error("Read-only tokens should not run session commands")
+	}
+	if !(client{scopes: []string{user.ScopeSessionsWrite}}).canWrite() {
+		t.Error("Tokens with sessions:write should run session commands")
+	}
+}
+
+// Reports one role for every member; methods the tests don't need are left
+// unimplemented
+type fakeGroupService struct {
+	group.GroupService
+	role group.Role
+}
+
+func (f *fakeGroupService) GetMemberRole(
+	ctx context.Context,
+	groupID uint64,
+	userEmail string,
+) (group.Role, error) {
+	return f.role, nil
+}
+
+func TestCommandsUseCurrentRole(t *testing.T) {
+	groups := &fakeGroupService{role: group.RoleMember}
+	s := NewServer(
+		nil,
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		WithGroupService(groups),
+	)
+	c := connect(s, 9, 1)
+	s.joinMeeting(9, []uint64{1}, time.Minute, c)
+	var conn *websocket.Conn
+	for conn = range s.sessions.room[9] {
+	}
+
+	// Demoted after connecting
+	groups.role = group.RoleViewer
+	err := s.runCommand(context.Background(), 9, conn, commandRaiseHand)
+	if !errors.Is(err, errCannotParticipate) {
+		t.Errorf("Expected a demoted viewer to be refused, got %v", err)
+	}
+}
@@ -3,8 +3,12 @@ package web
 import (
 	"log/slog"
 	"net/http"
+	"time"
 
+	"github.com/dilithaw123/broccoli-backend/internal/bus"
 	"github.com/dilithaw123/broccoli-backend/internal/group"
+	"github.com/dilithaw123/broccoli-backend/internal/mail"
+	"github.com/dilithaw123/broccoli-backend/internal/oidc"
 	"github.com/dilithaw123/broccoli-backend/internal/session"
 	"github.com/dilithaw123/broccoli-backend/internal/user"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -30,12 +34,100 @@ func WithUserService(userService user.UserService) BuilderOpts {
 	}
 }
 
+func WithRefreshTokenService(refreshTokenService user.RefreshTokenService) BuilderOpts {
+	return func(s *Server) {
+		s.refreshTokenService = refreshTokenService
+	}
+}
+
+func WithRefreshTokenTTL(ttl time.Duration) BuilderOpts {
+	return func(s *Server) {
+		s.refreshTokenTTL = ttl
+	}
+}
+
+func WithLoginTokenService(loginTokenService user.LoginTokenService) BuilderOpts {
+	return func(s *Server) {
+		s.loginTokenService = loginTokenService
+	}
+}
+
+func WithPersonalAccessTokenService(
+	accessTokenService user.PersonalAccessTokenService,
+) BuilderOpts {
+	return func(s *Server) {
+		s.accessTokenService = accessTokenService
+	}
+}
+
+func WithMailer(mailer mail.Mailer) BuilderOpts {
+	return func(s *Server) {
+		s.mailer = mailer
+	}
+}
+
+// Frontend page that magic links point to. It receives the token in the
+// token query parameter and posts it to /login/magic/verify.
+func WithMagicLinkURL(url string) BuilderOpts {
+	return func(s *Server) {
+		s.magicLinkURL = url
+	}
+}
+
+// Enables /login/oidc/{name} for the provider. Can be given once per provider.
+func WithOIDCProvider(provider *oidc.Provider) BuilderOpts {
+	return func(s *Server) {
+		s.oidcProviders[provider.Name] = provider
+	}
+}
+
+// Where the browser is sent after a successful OIDC login
+func WithPostLoginURL(url string) BuilderOpts {
+	return func(s *Server) {
+		s.postLoginURL = url
+	}
+}
+
 func WithGroupService(groupService group.GroupService) BuilderOpts {
 	return func(s *Server) {
 		s.groupService = groupService
 	}
 }
 
+func WithInviteService(inviteService group.InviteService) BuilderOpts {
+	return func(s *Server) {
+		s.inviteService = inviteService
+	}
+}
+
+// Frontend page that invite links point to. It receives the token in the
+// token query parameter and posts it to /invite/accept.
+func WithInviteURL(url string) BuilderOpts {
+	return func(s *Server) {
+		s.inviteURL = url
+	}
+}
+
+// Enables the scheduler that opens sessions on each group's schedule
+func WithScheduleService(scheduleService group.ScheduleService) BuilderOpts {
+	return func(s *Server) {
+		s.scheduleService = scheduleService
+	}
+}
+
+// How often the scheduler looks for sessions to open
+func WithSchedulerInterval(interval time.Duration) BuilderOpts {
+	return func(s *Server) {
+		s.schedulerInterval = interval
+	}
+}
+
+func WithSkipDateService(skipDateService group.SkipDateService) BuilderOpts {
+	return func(s *Server) {
+		s.skipDateService = skipDateService
+	}
+}
+
 func WithSessionService(sessionService session.SessionService) BuilderOpts {
 	return func(s *Server) {
 		s.sessionService = sessionService
@@ -48,14 +140,72 @@ func WithMux(mux *http.ServeMux) BuilderOpts {
 	}
 }
 
+// Signs and verifies access tokens with a single HMAC secret
 func WithSecretKey(secretKey string) BuilderOpts {
 	return func(s *Server) {
-		s.secretKey = secretKey
+		s.keys, _ = NewKeyring(NewHMACKey(secretKey))
+	}
+}
+
+func WithKeyring(keys *Keyring) BuilderOpts {
+	return func(s *Server) {
+		s.keys = keys
+	}
+}
+
+// Sets the iss claim written to and required of access tokens
+func WithTokenIssuer(issuer string) BuilderOpts {
+	return func(s *Server) {
+		s.tokenIssuer = issuer
+	}
+}
+
+// Sets the aud claim written to and required of access tokens
+func WithTokenAudience(audience string) BuilderOpts {
+	return func(s *Server) {
+		s.tokenAudience = audience
 	}
 }
 
+// Enables the legacy /login endpoint gated by the X-Api-Key header
 func WithApiKey(key string) BuilderOpts {
 	return func(s *Server) {
 		s.apiKey = key
 	}
 }
+
+// Sets the Domain attribute of the auth cookies. Empty means host-only.
+func WithCookieDomain(domain string) BuilderOpts {
+	return func(s *Server) {
+		s.cookies.Domain = domain
+	}
+}
+
+// Disabling secure cookies is only meant for local development over plain HTTP
+func WithSecureCookies(secure bool) BuilderOpts {
+	return func(s *Server) {
+		s.cookies.Secure = secure
+	}
+}
+
+func WithCookieSameSite(sameSite http.SameSite) BuilderOpts {
+	return func(s *Server) {
+		s.cookies.SameSite = sameSite
+	}
+}
+
+// Keeps returning tokens in response bodies for clients that don't use the
+// auth cookies yet
+func WithTokensInBody(enabled bool) BuilderOpts {
+	return func(s *Server) {
+		s.cookies.TokensInBody = enabled
+	}
+}
+
+// Connects the server's rooms to other instances. Without one the server
+// keeps them in memory and can't be scaled out.
+func WithBus(b bus.Bus) BuilderOpts {
+	return func(s *Server) {
+		s.bus = b
+	}
+}
@@ -0,0 +1,57 @@
+package web
+
+import (
+	"net/http"
+	"time"
+)
+
+const (
+	accessTokenCookie  = "access_token"
+	refreshTokenCookie = "refresh_token"
+	// The refresh token is only needed by /user/refresh, /user/logout and the
+	// device endpoints, so it is not sent along with every other request.
+	refreshTokenPath = "/user"
+)
+
+type cookieOptions struct {
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+	// Also return the tokens in login and refresh responses, for clients that
+	// predate the cookies. Scripts can read them there, so it is off by default.
+	TokensInBody bool
+}
+
+func (s *Server) setAuthCookies(w http.ResponseWriter, accessToken, refreshToken string) {
+	http.SetCookie(w, s.newCookie(accessTokenCookie, accessToken, "/", accessTokenTTL))
+	http.SetCookie(
+		w,
+		s.newCookie(refreshTokenCookie, refreshToken, refreshTokenPath, s.refreshTokenTTL),
+	)
+}
+
+func (s *Server) clearAuthCookies(w http.ResponseWriter) {
+	http.SetCookie(w, s.newCookie(accessTokenCookie, "", "/", -1))
+	http.SetCookie(w, s.newCookie(refreshTokenCookie, "", refreshTokenPath, -1))
+	// Clients used to set the refresh token themselves on the root path
+	http.SetCookie(w, s.newCookie(refreshTokenCookie, "", "/", -1))
+}
+
+func (s *Server) newCookie(name, value, path string, maxAge time.Duration) *http.Cookie {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   s.cookies.Domain,
+		HttpOnly: true,
+		Secure:   s.cookies.Secure,
+		SameSite: s.cookies.SameSite,
+	}
+	if maxAge < 0 {
+		c.MaxAge = -1
+	} else {
+		c.MaxAge = int(maxAge.Seconds())
+		c.Expires = time.Now().Add(maxAge).UTC()
+	}
+	return c
+}
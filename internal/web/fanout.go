@@ -0,0 +1,155 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/dilithaw123/broccoli-backend/internal/bus"
+	"github.com/dilithaw123/broccoli-backend/internal/group"
+)
+
+// Kinds of bus events the server publishes about its rooms
+const (
+	// Payload is an outgoing event for every connection in the room
+	eventMessage = "message"
+	// Payload is the meeting's new meetingState
+	eventMeeting = "meeting"
+	// Asks instances that already run the meeting to publish its state
+	eventMeetingSync = "meeting_sync"
+	// The session's submissions changed and should be fetched again
+	eventSubmissions = "submissions"
+	// Payload is a groupMember whose connections to the group must close
+	eventDisconnect = "disconnect"
+)
+
+type groupMember struct {
+	GroupID uint64 `json:"group_id"`
+	Email   string `json:"email"`
+}
+
+func newInstanceID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Publishes to every instance. When the bus is down the event still reaches
+// this instance's own clients.
+func (s *Server) publish(ctx context.Context, sessionId uint64, kind string, v any) {
+	e := bus.Event{SessionID: sessionId, Kind: kind, Origin: s.instanceID}
+	if v != nil {
+		payload, err := json.Marshal(v)
+		if err != nil {
+			s.logger.Error("Failed to encode event", "error", err, "kind", kind)
+			return
+		}
+		e.Payload = payload
+	}
+	if err := s.bus.Publish(ctx, e); err != nil {
+		s.logger.Error("Failed to publish event", "error", err, "kind", kind)
+		s.handleEvent(e)
+	}
+}
+
+// Applies events from every instance, this one included, to local rooms
+func (s *Server) relayEvents(events <-chan bus.Event) {
+	for e := range events {
+		s.handleEvent(e)
+	}
+}
+
+func (s *Server) handleEvent(e bus.Event) {
+	remote := e.Origin != s.instanceID
+	switch e.Kind {
+	case eventMessage:
+		var o struct {
+			Type    string          `json:"type"`
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := json.Unmarshal(e.Payload, &o); err != nil {
+			s.logger.Error("Failed to decode event", "error", err)
+			return
+		}
+		s.deliver(e.SessionID, outgoing{Type: o.Type, Payload: o.Payload})
+	case eventMeeting:
+		if !remote {
+			s.deliver(e.SessionID, outgoing{Type: typeMeetingState, Payload: e.Payload})
+			return
+		}
+		var st meetingState
+		if err := json.Unmarshal(e.Payload, &st); err != nil {
+			s.logger.Error("Failed to decode meeting state", "error", err)
+			return
+		}
+		s.adoptMeeting(e.SessionID, st)
+	case eventMeetingSync:
+		if remote {
+			s.syncMeeting(e.SessionID)
+		}
+	case eventSubmissions:
+		s.changes.add(e.SessionID)
+	case eventDisconnect:
+		var member groupMember
+		if err := json.Unmarshal(e.Payload, &member); err != nil {
+			s.logger.Error("Failed to decode disconnect", "error", err)
+			return
+		}
+		s.closeGroupConns(member.GroupID, member.Email)
+	}
+}
+
+// Takes on another instance's meeting state, adding anyone connected here who
+// is missing from it. When that changes the queue, the merged state goes back
+// out to every instance.
+func (s *Server) adoptMeeting(sessionId uint64, st meetingState) {
+	s.sessions.Lock()
+	m, ok := s.sessions.meetings[sessionId]
+	if !ok {
+		s.sessions.Unlock()
+		return
+	}
+	now := time.Now()
+	m.restore(st)
+	s.watchTimer(sessionId, m, now)
+	changed := false
+	for _, c := range s.sessions.room[sessionId] {
+		if c.role.Can(group.PermSubmit) && m.join(c.userID) {
+			changed = true
+		}
+	}
+	state := m.state(now)
+	if !changed {
+		for conn, c := range s.sessions.room[sessionId] {
+			s.enqueue(sessionId, conn, c, outgoing{Type: typeMeetingState, Payload: state})
+		}
+	}
+	s.sessions.Unlock()
+	if changed {
+		// Our own copy of the event delivers it here too
+		s.publish(context.Background(), sessionId, eventMeeting, state)
+	}
+}
+
+func (s *Server) syncMeeting(sessionId uint64) {
+	s.sessions.Lock()
+	m, ok := s.sessions.meetings[sessionId]
+	if !ok {
+		s.sessions.Unlock()
+		return
+	}
+	state := m.state(time.Now())
+	s.sessions.Unlock()
+	s.publish(context.Background(), sessionId, eventMeeting, state)
+}
+
+// Queues o for this instance's connections to the session
+func (s *Server) deliver(sessionId uint64, o outgoing) {
+	s.sessions.Lock()
+	defer s.sessions.Unlock()
+	for conn, c := range s.sessions.room[sessionId] {
+		s.enqueue(sessionId, conn, c, o)
+	}
+}
@@ -0,0 +1,190 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/dilithaw123/broccoli-backend/internal/bus"
+	"github.com/dilithaw123/broccoli-backend/internal/group"
+)
+
+func TestMeetingStateReachesOtherInstances(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := bus.NewMemoryBus()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := NewServer(nil, WithLogger(logger), WithBus(b))
+	other := NewServer(nil, WithLogger(logger), WithBus(b))
+	events, _ := b.Subscribe(ctx)
+
+	now := time.Now()
+	theirs := newMeeting([]uint64{1, 2, 3}, time.Minute)
+	other.sessions.meetings[9] = theirs
+	ours := newMeeting([]uint64{1, 2, 3}, time.Minute)
+	ours.next(now)
+	ours.next(now)
+	ours.timer.start(now)
+	a.publish(ctx, 9, eventMeeting, ours.state(now))
+
+	other.handleEvent(<-events)
+	if id, ok := theirs.speaker(); !ok || id != 2 || !theirs.timer.running {
+		t.Errorf("Expected speaker 2 with a running timer, got %d %+v", id, theirs.timer)
+	}
+	if !theirs.timer.deadline.Equal(ours.timer.deadline) {
+		t.Errorf("Expected deadline %v, got %v", ours.timer.deadline, theirs.timer.deadline)
+	}
+}
+
+// Hands every event on the bus to its subscribers until it goes quiet
+func pump(t *testing.T, servers []*Server, subs []<-chan bus.Event) {
+	t.Helper()
+	for {
+		delivered := false
+		for i, sub := range subs {
+			select {
+			case e := <-sub:
+				servers[i].handleEvent(e)
+				delivered = true
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+		if !delivered {
+			return
+		}
+	}
+}
+
+func connect(s *Server, sessionId, userID uint64) client {
+	c := client{userID: userID, role: group.RoleMember, send: make(chan outgoing, sendQueueSize)}
+	s.addToSessionMap(sessionId, new(websocket.Conn), c)
+	return c
+}
+
+func TestJoinOnNewInstanceKeepsMeeting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := bus.NewMemoryBus()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := NewServer(nil, WithLogger(logger), WithBus(b))
+	other := NewServer(nil, WithLogger(logger), WithBus(b))
+	subA, _ := b.Subscribe(ctx)
+	subB, _ := b.Subscribe(ctx)
+
+	watcher := connect(a, 9, 1)
+	a.joinMeeting(9, []uint64{1, 2, 3}, time.Minute, watcher)
+	a.sessions.meetings[9].next(time.Now())
+	a.sessions.meetings[9].next(time.Now())
+
+	c := connect(other, 9, 4)
+	state, changed, created := other.joinMeeting(9, []uint64{1, 2, 3}, time.Minute, c)
+	other.announceJoin(ctx, 9, state, changed, created)
+	pump(t, []*Server{a, other}, []<-chan bus.Event{subA, subB})
+
+	want := []uint64{1, 2, 3, 4}
+	for name, s := range map[string]*Server{"a": a, "other": other} {
+		m := s.sessions.meetings[9]
+		if id, ok := m.speaker(); !ok || id != 2 || !slices.Equal(m.queue, want) {
+			t.Errorf("%s: expected speaker 2 of %v, got %d of %v", name, want, id, m.queue)
+		}
+	}
+	// Clients already in the meeting never see it start over
+	for len(watcher.send) > 0 {
+		o := <-watcher.send
+		data, _ := json.Marshal(o.Payload)
+		var st meetingState
+		json.Unmarshal(data, &st)
+		if o.Type == typeMeetingState && st.Position != 1 {
+			t.Errorf("Expected position 1 in every update, got %+v", st)
+		}
+	}
+}
+
+func TestOvertimeOnEveryInstance(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := bus.NewMemoryBus()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := NewServer(nil, WithLogger(logger), WithBus(b))
+	other := NewServer(nil, WithLogger(logger), WithBus(b))
+	subA, _ := b.Subscribe(ctx)
+	subB, _ := b.Subscribe(ctx)
+	clients := []client{connect(a, 9, 1), connect(other, 9, 2)}
+	a.joinMeeting(9, []uint64{1, 2}, 10*time.Millisecond, clients[0])
+	other.joinMeeting(9, []uint64{1, 2}, 10*time.Millisecond, clients[1])
+
+	now := time.Now()
+	a.sessions.Lock()
+	m := a.sessions.meetings[9]
+	m.next(now)
+	m.timer.start(now)
+	a.watchTimer(9, m, now)
+	state := m.state(now)
+	a.sessions.Unlock()
+	a.publish(ctx, 9, eventMeeting, state)
+	pump(t, []*Server{a, other}, []<-chan bus.Event{subA, subB})
+	time.Sleep(50 * time.Millisecond)
+
+	select {
+	case e := <-subA:
+		t.Errorf("Expected overtime to stay off the bus, got %+v", e)
+	default:
+	}
+	for i, c := range clients {
+		overtime := 0
+		for len(c.send) > 0 {
+			if o := <-c.send; o.Type == typeOvertime {
+				overtime++
+			}
+		}
+		if overtime != 1 {
+			t.Errorf("client %d: expected one overtime event, got %d", i, overtime)
+		}
+	}
+}
+
+func TestDisconnectReachesOtherInstances(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	b := bus.NewMemoryBus()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	a := NewServer(nil, WithLogger(logger), WithBus(b))
+	other := NewServer(nil, WithLogger(logger), WithBus(b))
+	subA, _ := b.Subscribe(ctx)
+	subB, _ := b.Subscribe(ctx)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		c := client{userID: 1, email: "a@example.com", groupID: 5, send: make(chan outgoing)}
+		other.addToSessionMap(9, conn, c)
+		conn.Read(context.Background())
+	}))
+	defer ts.Close()
+	conn, _, err := websocket.Dial(ctx, ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.CloseNow()
+	for !other.hasListeners(9) {
+		time.Sleep(time.Millisecond)
+	}
+
+	a.disconnectFromGroup(ctx, 5, "a@example.com")
+	pump(t, []*Server{a, other}, []<-chan bus.Event{subA, subB})
+	readCtx, readCancel := context.WithTimeout(ctx, time.Second)
+	defer readCancel()
+	_, _, err = conn.Read(readCtx)
+	if status := websocket.CloseStatus(err); status != websocket.StatusPolicyViolation {
+		t.Errorf("Expected the connection closed as removed, got %v", err)
+	}
+}
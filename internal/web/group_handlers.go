@@ -2,7 +2,10 @@ package web
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/dilithaw123/broccoli-backend/internal/group"
@@ -10,8 +13,12 @@ import (
 
 func (s *Server) handleGetUserGroups() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		email := r.URL.Query().Get("email")
-		groups, err := s.groupService.GetGroupsByEmail(r.Context(), strings.ToLower(email))
+		email := principal(r).Email
+		if q := r.URL.Query().Get("email"); q != "" && strings.ToLower(q) != email {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		groups, err := s.groupService.GetGroupsByEmail(r.Context(), email)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -22,61 +29,156 @@ func (s *Server) handleGetUserGroups() http.HandlerFunc {
 	}
 }
 
+// The caller becomes the owner and only member of the new group. Everyone else
+// in allowed_emails is sent an invite to accept, like /group/user/add.
 func (s *Server) handlePostGroup() http.HandlerFunc {
-	type request group.Group
+	type request struct {
+		Name          string   `json:"name"`
+		Timezone      string   `json:"timezone"`
+		Description   string   `json:"description"`
+		AvatarURL     string   `json:"avatar_url"`
+		AllowedEmails []string `json:"allowed_emails"`
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req request
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		for ind, email := range req.AllowedEmails {
-			req.AllowedEmails[ind] = strings.ToLower(email)
+		owner := principal(r).Email
+		emails := []string{}
+		for _, email := range append(req.AllowedEmails, owner) {
+			email = strings.ToLower(strings.TrimSpace(email))
+			if !slices.Contains(emails, email) {
+				emails = append(emails, email)
+			}
 		}
-		if err := s.groupService.CreateGroup(r.Context(), group.Group(req)); err != nil {
-			switch err {
-			case group.ErrGroupExists:
-				http.Error(w, err.Error(), http.StatusBadRequest)
+		g := group.Group{
+			Name:          strings.TrimSpace(req.Name),
+			Timezone:      req.Timezone,
+			Description:   req.Description,
+			AvatarURL:     req.AvatarURL,
+			AllowedEmails: emails,
+		}
+		if err := g.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		g, err := s.groupService.CreateGroup(r.Context(), g, owner)
+		if err != nil {
+			switch {
+			case errors.Is(err, group.ErrGroupExists):
+				http.Error(w, err.Error(), http.StatusConflict)
 			default:
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 			}
 			return
 		}
-		w.WriteHeader(201)
+		for _, email := range emails {
+			if email == owner {
+				continue
+			}
+			token, inv := group.NewInvite(g.ID, email, group.RoleMember, owner, defaultInviteTTL, 0)
+			if _, _, err := s.sendInvite(r.Context(), token, inv); err != nil {
+				// The group exists either way; the owner can invite them again
+				s.logger.Error("Failed to invite to new group", "error", err, "groupId", g.ID)
+			}
+		}
+		w.Header().Set("Location", "/group/"+strconv.FormatUint(g.ID, 10))
+		if err := respondJSON(w, http.StatusCreated, g); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 	}
 }
 
+func (s *Server) handleGetGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.authorizeGroup(w, r, id, group.PermRead); !ok {
+			return
+		}
+		g, err := s.groupService.GetGroup(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, group.ErrGroupNotFound) {
+				http.Error(w, "group not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := respondJSON(w, http.StatusOK, g); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// Kept for older clients. Users are no longer added directly; they are sent
+// an invite to accept instead.
 func (s *Server) handleAddUserToGroup() http.HandlerFunc {
+	type request struct {
+		UserEmail string     `json:"email"`
+		GroupID   uint64     `json:"group_id"`
+		Role      group.Role `json:"role"`
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		type request struct {
-			UserEmail string `json:"email"`
-			GroupID   uint64 `json:"group_id"`
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
 		}
+		if req.UserEmail == "" {
+			http.Error(w, "email is required", http.StatusBadRequest)
+			return
+		}
+		s.createInvite(w, r, req.GroupID, inviteRequest{Email: req.UserEmail, Role: req.Role})
+	}
+}
+
+// Changes a member's role. Nobody can change their own role, so a group
+// always keeps at least one owner.
+func (s *Server) handleSetMemberRole() http.HandlerFunc {
+	type request struct {
+		UserEmail string     `json:"email"`
+		GroupID   uint64     `json:"group_id"`
+		Role      group.Role `json:"role"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
 		var req request
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		email := r.Context().Value("email").(string)
-		userAllowed, err := s.groupService.GroupContainsUser(
-			r.Context(),
-			req.GroupID,
-			email,
-		)
+		req.UserEmail = strings.ToLower(req.UserEmail)
+		if !req.Role.Valid() {
+			http.Error(w, "invalid role", http.StatusBadRequest)
+			return
+		}
+		if req.UserEmail == principal(r).Email {
+			http.Error(w, "cannot change your own role", http.StatusBadRequest)
+			return
+		}
+		role, ok := s.authorizeGroup(w, r, req.GroupID, group.PermManageMembers)
+		if !ok {
+			return
+		}
+		current, err := s.groupService.GetMemberRole(r.Context(), req.GroupID, req.UserEmail)
 		if err != nil {
+			if errors.Is(err, group.ErrNotMember) {
+				http.Error(w, "user not in group", http.StatusNotFound)
+				return
+			}
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		if !userAllowed {
-			http.Error(w, "user not allowed", http.StatusForbidden)
+		if !role.CanAssign(current, req.Role) {
+			http.Error(w, "cannot change role", http.StatusForbidden)
 			return
 		}
-
-		err = s.groupService.AddUserToGroup(
-			r.Context(),
-			req.GroupID,
-			strings.ToLower(req.UserEmail),
-		)
+		err = s.groupService.SetMemberRole(r.Context(), req.GroupID, req.UserEmail, req.Role)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -85,10 +187,97 @@ func (s *Server) handleAddUserToGroup() http.HandlerFunc {
 	}
 }
 
+// Changes the settings present in the body and leaves the rest alone
+func (s *Server) handlePatchGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		var req group.GroupUpdate
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Name != nil {
+			name := strings.TrimSpace(*req.Name)
+			req.Name = &name
+		}
+		if err := req.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.authorizeGroup(w, r, id, group.PermManageSettings); !ok {
+			return
+		}
+		if !s.validFacilitatorUpdate(w, r, id, req) {
+			return
+		}
+		g, err := s.groupService.UpdateGroup(r.Context(), id, req)
+		if err != nil {
+			switch {
+			case errors.Is(err, group.ErrGroupExists):
+				http.Error(w, err.Error(), http.StatusConflict)
+			case errors.Is(err, group.ErrGroupNotFound):
+				http.Error(w, "group not found", http.StatusNotFound)
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		if err := respondJSON(w, http.StatusOK, g); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// A fixed facilitator has to be a member who can run sessions. When it isn't,
+// the error response has been written and false is returned.
+func (s *Server) validFacilitatorUpdate(
+	w http.ResponseWriter,
+	r *http.Request,
+	groupID uint64,
+	u group.GroupUpdate,
+) bool {
+	if u.FacilitatorPolicy == nil && u.FacilitatorEmail == nil {
+		return true
+	}
+	current, err := s.groupService.GetGroup(r.Context(), groupID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	policy, email := current.FacilitatorPolicy, current.FacilitatorEmail
+	if u.FacilitatorPolicy != nil {
+		policy = *u.FacilitatorPolicy
+	}
+	if u.FacilitatorEmail != nil {
+		*u.FacilitatorEmail = strings.ToLower(*u.FacilitatorEmail)
+		email = u.FacilitatorEmail
+	}
+	if email == nil || *email == "" {
+		if policy == group.FacilitatorFixed {
+			http.Error(w, group.ErrFacilitatorRequired.Error(), http.StatusBadRequest)
+			return false
+		}
+		return true
+	}
+	role, err := s.groupService.GetMemberRole(r.Context(), groupID, *email)
+	if err != nil && !errors.Is(err, group.ErrNotMember) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	if !role.Can(group.PermRunSession) {
+		http.Error(w, "facilitator must be a member who can run sessions", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
 func (s *Server) handleDeleteGroup() http.HandlerFunc {
 	type request struct {
-		GroupId   uint64 `json:"group_id"`
-		UserEmail string `json:"user_email"`
+		GroupId uint64 `json:"group_id"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req request
@@ -96,8 +285,11 @@ func (s *Server) handleDeleteGroup() http.HandlerFunc {
 			http.Error(w, "Bad request body", http.StatusBadRequest)
 			return
 		}
-		req.UserEmail = strings.ToLower(req.UserEmail)
-		if err := s.groupService.DeleteGroup(r.Context(), req.GroupId, req.UserEmail); err != nil {
+		if _, ok := s.authorizeGroup(w, r, req.GroupId, group.PermDeleteGroup); !ok {
+			return
+		}
+		err := s.groupService.DeleteGroup(r.Context(), req.GroupId, principal(r).Email)
+		if err != nil {
 			switch err {
 			case group.ErrGroupNotFound:
 				http.Error(w, "Group not found", http.StatusNotFound)
@@ -111,3 +303,135 @@ func (s *Server) handleDeleteGroup() http.HandlerFunc {
 		w.WriteHeader(http.StatusOK)
 	}
 }
+
+func (s *Server) handleGetGroupMembers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.authorizeGroup(w, r, id, group.PermRead); !ok {
+			return
+		}
+		members, err := s.groupService.GetGroupMembers(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := respondJSON(w, http.StatusOK, members); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// Takes someone else out of the group. Members leave through handleLeaveGroup.
+func (s *Server) handleRemoveUserFromGroup() http.HandlerFunc {
+	type request struct {
+		UserEmail string `json:"email"`
+		GroupID   uint64 `json:"group_id"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.UserEmail = strings.ToLower(req.UserEmail)
+		if req.UserEmail == principal(r).Email {
+			http.Error(w, "use leave to remove yourself", http.StatusBadRequest)
+			return
+		}
+		role, ok := s.authorizeGroup(w, r, req.GroupID, group.PermManageMembers)
+		if !ok {
+			return
+		}
+		target, err := s.groupService.GetMemberRole(r.Context(), req.GroupID, req.UserEmail)
+		if err != nil {
+			if errors.Is(err, group.ErrNotMember) {
+				http.Error(w, "user not in group", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !role.CanRemove(target) {
+			http.Error(w, "cannot remove a "+string(target), http.StatusForbidden)
+			return
+		}
+		s.removeMember(w, r, req.GroupID, req.UserEmail)
+	}
+}
+
+func (s *Server) handleLeaveGroup() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.authorizeGroup(w, r, id, group.PermRead); !ok {
+			return
+		}
+		s.removeMember(w, r, id, principal(r).Email)
+	}
+}
+
+func (s *Server) removeMember(
+	w http.ResponseWriter,
+	r *http.Request,
+	groupID uint64,
+	email string,
+) {
+	err := s.groupService.RemoveUserFromGroup(r.Context(), groupID, email)
+	if err != nil {
+		switch {
+		case errors.Is(err, group.ErrLastOwner):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, group.ErrNotMember):
+			http.Error(w, "user not in group", http.StatusNotFound)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	s.disconnectFromGroup(r.Context(), groupID, email)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Hands ownership to another member. The previous owner stays on as an admin.
+func (s *Server) handleTransferOwnership() http.HandlerFunc {
+	type request struct {
+		UserEmail string `json:"email"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.UserEmail = strings.ToLower(req.UserEmail)
+		if req.UserEmail == principal(r).Email {
+			http.Error(w, "already the owner", http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.authorizeGroup(w, r, id, group.PermTransferOwner); !ok {
+			return
+		}
+		err = s.groupService.TransferOwnership(r.Context(), id, principal(r).Email, req.UserEmail)
+		if err != nil {
+			if errors.Is(err, group.ErrNotMember) {
+				http.Error(w, "user not in group", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,299 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dilithaw123/broccoli-backend/internal/group"
+	broccolimail "github.com/dilithaw123/broccoli-backend/internal/mail"
+)
+
+const (
+	defaultInviteTTL = 7 * 24 * time.Hour
+	maxInviteTTL     = 30 * 24 * time.Hour
+)
+
+type inviteRequest struct {
+	// Leave empty for a shareable link
+	Email          string     `json:"email"`
+	Role           group.Role `json:"role"`
+	ExpiresInHours int        `json:"expires_in_hours"`
+	// Only used by shareable links; email invites can be used once
+	MaxUses int `json:"max_uses"`
+}
+
+type inviteResponse struct {
+	group.Invite
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// Invites someone to the group by email or creates a shareable invite link.
+// Membership is only created when the invite is accepted.
+func (s *Server) handlePostGroupInvite() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		var req inviteRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.createInvite(w, r, id, req)
+	}
+}
+
+func (s *Server) createInvite(
+	w http.ResponseWriter,
+	r *http.Request,
+	groupID uint64,
+	req inviteRequest,
+) {
+	if req.Email != "" {
+		addr, err := mail.ParseAddress(req.Email)
+		if err != nil {
+			http.Error(w, "invalid email", http.StatusBadRequest)
+			return
+		}
+		req.Email = strings.ToLower(addr.Address)
+	}
+	if req.Role == "" {
+		req.Role = group.RoleMember
+	}
+	if !req.Role.Valid() {
+		http.Error(w, "invalid role", http.StatusBadRequest)
+		return
+	}
+	ttl := time.Duration(req.ExpiresInHours) * time.Hour
+	if ttl == 0 {
+		ttl = defaultInviteTTL
+	}
+	if ttl < 0 || ttl > maxInviteTTL {
+		http.Error(w, "expires_in_hours must be between 1 and 720", http.StatusBadRequest)
+		return
+	}
+	if req.MaxUses < 0 {
+		http.Error(w, "max_uses must not be negative", http.StatusBadRequest)
+		return
+	}
+	role, ok := s.authorizeGroup(w, r, groupID, group.PermManageMembers)
+	if !ok {
+		return
+	}
+	if !role.CanAssign(group.RoleViewer, req.Role) {
+		http.Error(w, "cannot grant role "+string(req.Role), http.StatusForbidden)
+		return
+	}
+	if req.Email != "" {
+		member, err := s.groupService.GroupContainsUser(r.Context(), groupID, req.Email)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if member {
+			http.Error(w, "user is already a member", http.StatusConflict)
+			return
+		}
+	}
+
+	p := principal(r)
+	token, inv := group.NewInvite(groupID, req.Email, req.Role, p.Email, ttl, req.MaxUses)
+	inv, link, err := s.sendInvite(r.Context(), token, inv)
+	if err != nil {
+		if errors.Is(err, group.ErrGroupNotFound) {
+			http.Error(w, "group not found", http.StatusNotFound)
+			return
+		}
+		s.logger.Error("Create invite", "Error", err, "groupId", groupID)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp := inviteResponse{Invite: inv, Token: token, URL: link}
+	if err := respondJSON(w, http.StatusCreated, resp); err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+// Stores the invite and mails it when it is for an email. Returns the stored
+// invite and its link.
+func (s *Server) sendInvite(
+	ctx context.Context,
+	token string,
+	inv group.Invite,
+) (group.Invite, string, error) {
+	inv, err := s.inviteService.CreateInvite(ctx, inv)
+	if err != nil {
+		return group.Invite{}, "", err
+	}
+	link := s.inviteURL + "?token=" + url.QueryEscape(token)
+	if inv.Email != nil {
+		err = s.mailer.Send(ctx, broccolimail.Message{
+			To:      *inv.Email,
+			Subject: "You've been invited to " + inv.GroupName + " on Broccoli",
+			Body: inv.CreatedBy + " invited you to join " + inv.GroupName + " as a " +
+				string(inv.Role) + ". The invite expires on " + inv.ExpiresAt.String() +
+				".\n\n" + link + "\n",
+		})
+		if err != nil {
+			// The invite still shows up in the invitee's pending invites
+			s.logger.Error("Failed to send invite", "Error", err, "email", *inv.Email)
+		}
+	}
+	return inv, link, nil
+}
+
+func (s *Server) handleGetGroupInvites() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.authorizeGroup(w, r, id, group.PermManageMembers); !ok {
+			return
+		}
+		invites, err := s.inviteService.GetPendingGroupInvites(r.Context(), id)
+		if err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := respondJSON(w, http.StatusOK, invites); err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func (s *Server) handleRevokeGroupInvite() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		inviteID, err := strconv.ParseUint(r.PathValue("invite_id"), 10, 64)
+		if err != nil {
+			http.Error(w, "invite_id must be an integer", http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.authorizeGroup(w, r, id, group.PermManageMembers); !ok {
+			return
+		}
+		if err = s.inviteService.RevokeInvite(r.Context(), id, inviteID); err != nil {
+			if errors.Is(err, group.ErrInviteNotFound) {
+				http.Error(w, "invite not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Pending invites sent to the caller's email
+func (s *Server) handleGetUserInvites() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		invites, err := s.inviteService.GetPendingInvitesByEmail(r.Context(), principal(r).Email)
+		if err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := respondJSON(w, http.StatusOK, invites); err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// Invites are picked by the token from a link or, for invites sent to the
+// caller and listed by handleGetUserInvites, by id.
+type inviteRef struct {
+	Token string `json:"token"`
+	ID    uint64 `json:"id"`
+}
+
+// Returns the invite's id and whether the caller knew its token
+func (s *Server) resolveInvite(w http.ResponseWriter, r *http.Request) (uint64, bool, bool) {
+	var ref inviteRef
+	if err := json.NewDecoder(r.Body).Decode(&ref); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return 0, false, false
+	}
+	if ref.Token == "" {
+		if ref.ID == 0 {
+			http.Error(w, "token or id is required", http.StatusBadRequest)
+			return 0, false, false
+		}
+		return ref.ID, false, true
+	}
+	inv, err := s.inviteService.GetInviteByToken(r.Context(), group.HashInviteToken(ref.Token))
+	if err != nil {
+		if errors.Is(err, group.ErrInviteNotFound) {
+			http.Error(w, "invite not found", http.StatusNotFound)
+			return 0, false, false
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return 0, false, false
+	}
+	return inv.ID, true, true
+}
+
+func (s *Server) handleAcceptInvite() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, withToken, ok := s.resolveInvite(w, r)
+		if !ok {
+			return
+		}
+		inv, err := s.inviteService.AcceptInvite(r.Context(), id, principal(r).Email, withToken)
+		if err != nil {
+			switch {
+			case errors.Is(err, group.ErrInviteNotFound):
+				http.Error(w, "invite not found", http.StatusNotFound)
+			case errors.Is(err, group.ErrInviteNotForUser):
+				http.Error(w, err.Error(), http.StatusForbidden)
+			case errors.Is(err, group.ErrInviteExpired):
+				http.Error(w, err.Error(), http.StatusGone)
+			default:
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+		g, err := s.groupService.GetGroup(r.Context(), inv.GroupID)
+		if err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := respondJSON(w, http.StatusOK, g); err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func (s *Server) handleDeclineInvite() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, _, ok := s.resolveInvite(w, r)
+		if !ok {
+			return
+		}
+		// Only invites sent to the caller can be declined, token or not
+		err := s.inviteService.DeclineInvite(r.Context(), id, principal(r).Email)
+		if err != nil {
+			if errors.Is(err, group.ErrInviteNotFound) {
+				http.Error(w, "invite not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,48 @@
+package web
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dilithaw123/broccoli-backend/internal/group"
+)
+
+// Serves a single invite; methods the tests don't need are left unimplemented
+type fakeInviteService struct {
+	group.InviteService
+	invite group.Invite
+}
+
+func (f *fakeInviteService) AcceptInvite(
+	ctx context.Context,
+	id uint64,
+	userEmail string,
+	withToken bool,
+) (group.Invite, error) {
+	if id != f.invite.ID {
+		return group.Invite{}, group.ErrInviteNotFound
+	}
+	return f.invite, f.invite.CheckAccept(userEmail, withToken)
+}
+
+func TestAcceptLinkInviteByID(t *testing.T) {
+	_, link := group.NewInvite(1, "", group.RoleAdmin, "owner@example.com", defaultInviteTTL, 0)
+	link.ID = 3
+	s := NewServer(
+		nil,
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		WithInviteService(&fakeInviteService{invite: link}),
+	)
+	r := httptest.NewRequest(http.MethodPost, "/invite/accept", strings.NewReader(`{"id": 3}`))
+	r = r.WithContext(withPrincipal(r.Context(), Principal{ID: 9, Email: "guess@example.com"}))
+	w := httptest.NewRecorder()
+	s.handleAcceptInvite()(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("Expected 404 for a link invite accepted by id, got %d", w.Code)
+	}
+}
@@ -0,0 +1,213 @@
+package web
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKeyID     = errors.New("unknown key id")
+	ErrUnsupportedKey   = errors.New("unsupported key type")
+	ErrNoSigningKey     = errors.New("key cannot sign")
+	ErrInvalidKeyFormat = errors.New("invalid PEM key")
+)
+
+// A SigningKey is one entry of a Keyring. Keys without a private half can only
+// verify tokens, which is how retired keys stay valid until their tokens expire.
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	private crypto.PrivateKey
+	public  crypto.PublicKey
+}
+
+func NewHMACKey(secret string) SigningKey {
+	sum := sha256.Sum256([]byte(secret))
+	return SigningKey{
+		ID:      "hs-" + hex.EncodeToString(sum[:8]),
+		Method:  jwt.SigningMethodHS256,
+		private: []byte(secret),
+		public:  []byte(secret),
+	}
+}
+
+// Parses a PKCS#8 (or PKCS#1 for RSA) private key. Ed25519 keys sign with
+// EdDSA and RSA keys with RS256.
+func ParsePrivateKeyPEM(data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, ErrInvalidKeyFormat
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if key, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return SigningKey{}, err
+		}
+	}
+	switch k := key.(type) {
+	case ed25519.PrivateKey:
+		return newAsymmetricKey(jwt.SigningMethodEdDSA, k, k.Public())
+	case *rsa.PrivateKey:
+		return newAsymmetricKey(jwt.SigningMethodRS256, k, &k.PublicKey)
+	default:
+		return SigningKey{}, ErrUnsupportedKey
+	}
+}
+
+// Parses a PKIX public key that can only be used for verification
+func ParsePublicKeyPEM(data []byte) (SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, ErrInvalidKeyFormat
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	switch k := key.(type) {
+	case ed25519.PublicKey:
+		return newAsymmetricKey(jwt.SigningMethodEdDSA, nil, k)
+	case *rsa.PublicKey:
+		return newAsymmetricKey(jwt.SigningMethodRS256, nil, k)
+	default:
+		return SigningKey{}, ErrUnsupportedKey
+	}
+}
+
+// Key IDs are derived from the public key so every instance agrees on them
+func newAsymmetricKey(
+	method jwt.SigningMethod,
+	private crypto.PrivateKey,
+	public crypto.PublicKey,
+) (SigningKey, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	sum := sha256.Sum256(der)
+	return SigningKey{
+		ID:      hex.EncodeToString(sum[:8]),
+		Method:  method,
+		private: private,
+		public:  public,
+	}, nil
+}
+
+// A Keyring signs tokens with a single key and verifies them with any key it
+// holds, looked up by the kid header.
+type Keyring struct {
+	signingID string
+	keys      map[string]SigningKey
+}
+
+func NewKeyring(signing SigningKey, verifyOnly ...SigningKey) (*Keyring, error) {
+	if signing.private == nil {
+		return nil, ErrNoSigningKey
+	}
+	k := &Keyring{
+		signingID: signing.ID,
+		keys:      map[string]SigningKey{signing.ID: signing},
+	}
+	for _, key := range verifyOnly {
+		if _, ok := k.keys[key.ID]; !ok {
+			k.keys[key.ID] = key
+		}
+	}
+	return k, nil
+}
+
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key := k.keys[k.signingID]
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
+}
+
+// Tokens issued before key IDs existed have no kid and are checked against
+// every key of their algorithm, so they outlive a move to a new kind of key.
+func (k *Keyring) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		var set jwt.VerificationKeySet
+		for _, key := range k.keys {
+			if key.Method.Alg() == token.Method.Alg() {
+				set.Keys = append(set.Keys, key.public)
+			}
+		}
+		if len(set.Keys) == 0 {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return set, nil
+	}
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKeyID
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, jwt.ErrSignatureInvalid
+	}
+	return key.public, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// Returns the asymmetric public keys as a JSON Web Key Set. Shared HMAC
+// secrets are never published.
+func (k *Keyring) JWKS() []jwk {
+	keys := []jwk{}
+	for _, key := range k.keys {
+		switch pub := key.public.(type) {
+		case ed25519.PublicKey:
+			keys = append(keys, jwk{
+				Kty: "OKP",
+				Kid: key.ID,
+				Alg: key.Method.Alg(),
+				Use: "sig",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		case *rsa.PublicKey:
+			keys = append(keys, jwk{
+				Kty: "RSA",
+				Kid: key.ID,
+				Alg: key.Method.Alg(),
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		}
+	}
+	return keys
+}
+
+func (s *Server) handleJWKS() http.HandlerFunc {
+	type response struct {
+		Keys []jwk `json:"keys"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		if err := respondJSON(w, http.StatusOK, response{Keys: s.keys.JWKS()}); err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}
+}
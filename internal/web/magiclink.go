@@ -0,0 +1,147 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	broccolimail "github.com/dilithaw123/broccoli-backend/internal/mail"
+	"github.com/dilithaw123/broccoli-backend/internal/types"
+	"github.com/dilithaw123/broccoli-backend/internal/user"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const magicLinkTTL = 15 * time.Minute
+
+type magicLinkClaims struct {
+	jwt.RegisteredClaims
+	Name string `json:"name,omitempty"`
+}
+
+// Magic link tokens are signed by the same keyring as access tokens but carry
+// their own audience, so neither can be used in place of the other.
+func (s *Server) magicLinkAudience() string {
+	return s.tokenAudience + "/magic-link"
+}
+
+// Emails a single use login link. Answers 202 whether or not the email has an
+// account, so the endpoint cannot be used to find out which do. Asking again
+// too soon, for the same email or from the same IP, gets a 429.
+func (s *Server) handleRequestMagicLink() http.HandlerFunc {
+	type request struct {
+		Email string `json:"email"`
+		Name  string `json:"name"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		addr, err := mail.ParseAddress(req.Email)
+		if err != nil {
+			http.Error(w, "invalid email", http.StatusBadRequest)
+			return
+		}
+		email := strings.ToLower(addr.Address)
+		now := time.Now().UTC()
+		jti := user.NewJTI()
+		claims := magicLinkClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:        jti,
+				Subject:   email,
+				Issuer:    s.tokenIssuer,
+				Audience:  jwt.ClaimStrings{s.magicLinkAudience()},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(magicLinkTTL)),
+			},
+			Name: req.Name,
+		}
+		token, err := s.keys.Sign(claims)
+		if err != nil {
+			s.logger.Error("Magic link", "Error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		err = s.loginTokenService.CreateLoginToken(r.Context(), user.LoginToken{
+			JTI:       jti,
+			Email:     email,
+			IP:        clientIP(r),
+			ExpiresAt: types.CustomTime(now.Add(magicLinkTTL)),
+		})
+		if errors.Is(err, user.ErrLoginTokenThrottled) {
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
+		if err != nil {
+			s.logger.Error("Magic link", "Error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		link := s.magicLinkURL + "?token=" + url.QueryEscape(token)
+		err = s.mailer.Send(r.Context(), broccolimail.Message{
+			To:      email,
+			Subject: "Your Broccoli login link",
+			Body: "Use the link below to log in to Broccoli. It expires in " +
+				magicLinkTTL.String() + " and can only be used once.\n\n" + link + "\n",
+		})
+		if err != nil {
+			s.logger.Error("Failed to send magic link", "Error", err, "email", email)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// Exchanges a magic link token for an access and refresh token
+func (s *Server) handleVerifyMagicLink() http.HandlerFunc {
+	type request struct {
+		Token string `json:"token"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		var claims magicLinkClaims
+		_, err := jwt.ParseWithClaims(
+			req.Token,
+			&claims,
+			s.keys.keyFunc,
+			jwt.WithIssuer(s.tokenIssuer),
+			jwt.WithAudience(s.magicLinkAudience()),
+			jwt.WithExpirationRequired(),
+		)
+		if err != nil || claims.ID == "" {
+			http.Error(w, "invalid or expired link", http.StatusUnauthorized)
+			return
+		}
+		stored, err := s.loginTokenService.ConsumeLoginToken(r.Context(), claims.ID)
+		if err != nil {
+			if errors.Is(err, user.ErrLoginTokenInvalid) {
+				http.Error(w, "invalid or expired link", http.StatusUnauthorized)
+				return
+			}
+			s.logger.Error("Magic link", "Error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if stored.Email != claims.Subject {
+			http.Error(w, "invalid or expired link", http.StatusUnauthorized)
+			return
+		}
+		u, err := s.findOrCreateUser(r.Context(), stored.Email, claims.Name)
+		if err != nil {
+			s.logger.Error("Magic link", "Error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		s.completeLogin(w, r, u)
+	}
+}
@@ -0,0 +1,58 @@
+package web
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dilithaw123/broccoli-backend/internal/mail"
+	"github.com/dilithaw123/broccoli-backend/internal/user"
+)
+
+// Allows one link per email, like the database does within a minute
+type fakeLoginTokenService struct {
+	user.LoginTokenService
+	issued map[string]bool
+}
+
+func (f *fakeLoginTokenService) CreateLoginToken(ctx context.Context, t user.LoginToken) error {
+	if f.issued[t.Email] {
+		return user.ErrLoginTokenThrottled
+	}
+	f.issued[t.Email] = true
+	return nil
+}
+
+type countingMailer struct{ sent int }
+
+func (m *countingMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.sent++
+	return nil
+}
+
+func TestMagicLinkThrottled(t *testing.T) {
+	keys, _ := NewKeyring(NewHMACKey("secret"))
+	mailer := &countingMailer{}
+	s := NewServer(
+		nil,
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		WithKeyring(keys),
+		WithLoginTokenService(&fakeLoginTokenService{issued: make(map[string]bool)}),
+		WithMailer(mailer),
+	)
+	for _, want := range []int{http.StatusAccepted, http.StatusTooManyRequests} {
+		body := strings.NewReader(`{"email": "a@example.com"}`)
+		w := httptest.NewRecorder()
+		s.handleRequestMagicLink()(w, httptest.NewRequest(http.MethodPost, "/magic", body))
+		if w.Code != want {
+			t.Errorf("Expected %d, got %d", want, w.Code)
+		}
+	}
+	if mailer.sent != 1 {
+		t.Errorf("Expected one email, got %d", mailer.sent)
+	}
+}
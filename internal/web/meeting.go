@@ -0,0 +1,224 @@
+package web
+
+import (
+	"slices"
+	"time"
+)
+
+// Commands clients send over the session websocket to run the meeting
+const (
+	commandNext       = "next"
+	commandPrev       = "prev"
+	commandSkip       = "skip"
+	commandRaiseHand  = "raise_hand"
+	commandLowerHand  = "lower_hand"
+	commandTimerStart = "timer_start"
+	commandTimerPause = "timer_pause"
+	commandTimerReset = "timer_reset"
+)
+
+// A meeting is the live state of a session's standup: who speaks in which
+// order and who is speaking now. The server holds it so every client, including
+// ones that join late, sees the same thing.
+type meeting struct {
+	queue []uint64
+	// Index into queue. -1 before anyone has spoken and len(queue) once everyone has.
+	current     int
+	raisedHands []uint64
+	timer       speakerTimer
+}
+
+// Each speaker gets a timebox. The server owns the deadline so every client
+// counts down to the same moment whatever their clock says.
+type speakerTimer struct {
+	length  time.Duration
+	running bool
+	// Set while running
+	deadline time.Time
+	// Time left when paused. Negative once the speaker has gone over.
+	remaining time.Duration
+	overtime  bool
+	// Bumped on every change so a pending overtime check can tell it is stale
+	generation int
+	// Generation the pending overtime check was scheduled for
+	watched int
+}
+
+// Sent to the room whenever the meeting changes and to clients when they join
+type meetingState struct {
+	Queue          []uint64   `json:"queue"`
+	CurrentSpeaker *uint64    `json:"current_speaker"`
+	Position       int        `json:"position"`
+	RaisedHands    []uint64   `json:"raised_hands"`
+	Timer          timerState `json:"timer"`
+}
+
+// Clients should count down from RemainingMS as of receiving the message,
+// rather than comparing Deadline to their own clock.
+type timerState struct {
+	Seconds     int        `json:"seconds"`
+	Running     bool       `json:"running"`
+	Deadline    *time.Time `json:"deadline,omitempty"`
+	RemainingMS int64      `json:"remaining_ms"`
+	Overtime    bool       `json:"overtime"`
+	ServerTime  time.Time  `json:"server_time"`
+}
+
+// Sent when the current speaker's time runs out
+type overtimeEvent struct {
+	Speaker  *uint64   `json:"speaker"`
+	Deadline time.Time `json:"deadline"`
+}
+
+// queue is the speaking order, normally the session's shuffled submissions
+func newMeeting(queue []uint64, speakerTime time.Duration) *meeting {
+	return &meeting{
+		queue:   slices.Clone(queue),
+		current: -1,
+		timer:   speakerTimer{length: speakerTime, remaining: speakerTime},
+	}
+}
+
+// Adds a speaker to the end of the queue unless they are already in it.
+// Reports whether the queue changed.
+func (m *meeting) join(userID uint64) bool {
+	if slices.Contains(m.queue, userID) {
+		return false
+	}
+	m.queue = append(m.queue, userID)
+	return true
+}
+
+func (m *meeting) speaker() (uint64, bool) {
+	if m.current < 0 || m.current >= len(m.queue) {
+		return 0, false
+	}
+	return m.queue[m.current], true
+}
+
+func (m *meeting) next(now time.Time) {
+	if m.current < len(m.queue) {
+		m.current++
+	}
+	m.speakerUp(now)
+}
+
+func (m *meeting) prev(now time.Time) {
+	if m.current > -1 {
+		m.current--
+	}
+	m.speakerUp(now)
+}
+
+// Sends the current speaker to the back of the queue and hands over to the
+// next one.
+func (m *meeting) skip(now time.Time) {
+	id, ok := m.speaker()
+	if !ok {
+		return
+	}
+	m.queue = append(slices.Delete(m.queue, m.current, m.current+1), id)
+	if m.current == len(m.queue)-1 {
+		// Skipping the last speaker leaves nobody else to hear from
+		m.current = len(m.queue)
+	}
+	m.speakerUp(now)
+}
+
+// Whoever has the floor no longer needs their hand up and gets a fresh
+// timebox, which keeps running if it was.
+func (m *meeting) speakerUp(now time.Time) {
+	running := m.timer.running
+	m.timer.reset()
+	id, ok := m.speaker()
+	if !ok {
+		return
+	}
+	m.lowerHand(id)
+	if running {
+		m.timer.start(now)
+	}
+}
+
+func (t *speakerTimer) start(now time.Time) {
+	if t.running {
+		return
+	}
+	t.running = true
+	t.deadline = now.Add(t.remaining)
+	t.generation++
+}
+
+func (t *speakerTimer) pause(now time.Time) {
+	if !t.running {
+		return
+	}
+	t.running = false
+	t.remaining = t.deadline.Sub(now)
+	t.generation++
+}
+
+func (t *speakerTimer) reset() {
+	t.running = false
+	t.remaining = t.length
+	t.overtime = false
+	t.generation++
+}
+
+func (t *speakerTimer) left(now time.Time) time.Duration {
+	if t.running {
+		return t.deadline.Sub(now)
+	}
+	return t.remaining
+}
+
+func (m *meeting) raiseHand(userID uint64) {
+	if !slices.Contains(m.raisedHands, userID) {
+		m.raisedHands = append(m.raisedHands, userID)
+	}
+}
+
+func (m *meeting) lowerHand(userID uint64) {
+	m.raisedHands = slices.DeleteFunc(m.raisedHands, func(id uint64) bool { return id == userID })
+}
+
+func (m *meeting) state(now time.Time) meetingState {
+	st := meetingState{
+		Queue:       slices.Clone(m.queue),
+		Position:    m.current,
+		RaisedHands: slices.Clone(m.raisedHands),
+		Timer: timerState{
+			Seconds:     int(m.timer.length / time.Second),
+			Running:     m.timer.running,
+			RemainingMS: m.timer.left(now).Milliseconds(),
+			Overtime:    m.timer.overtime,
+			ServerTime:  now,
+		},
+	}
+	if m.timer.running {
+		deadline := m.timer.deadline
+		st.Timer.Deadline = &deadline
+	}
+	if st.RaisedHands == nil {
+		st.RaisedHands = []uint64{}
+	}
+	if id, ok := m.speaker(); ok {
+		st.CurrentSpeaker = &id
+	}
+	return st
+}
+
+// Takes on a meeting as another instance of the server last saw it
+func (m *meeting) restore(st meetingState) {
+	m.queue = slices.Clone(st.Queue)
+	m.current = st.Position
+	m.raisedHands = slices.Clone(st.RaisedHands)
+	m.timer.length = time.Duration(st.Timer.Seconds) * time.Second
+	m.timer.running = st.Timer.Running
+	m.timer.remaining = time.Duration(st.Timer.RemainingMS) * time.Millisecond
+	if st.Timer.Deadline != nil {
+		m.timer.deadline = *st.Timer.Deadline
+	}
+	m.timer.overtime = st.Timer.Overtime
+	m.timer.generation++
+}
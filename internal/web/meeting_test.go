@@ -0,0 +1,130 @@
+package web
+
+import (
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dilithaw123/broccoli-backend/internal/user"
+)
+
+func TestMeetingSpeakerOrder(t *testing.T) {
+	now := time.Now()
+	m := newMeeting([]uint64{1, 2, 3}, time.Minute)
+	if _, ok := m.speaker(); ok {
+		t.Fatal("Nobody should be speaking before the meeting starts")
+	}
+	m.next(now)
+	if id, _ := m.speaker(); id != 1 {
+		t.Errorf("Expected speaker 1, got %d", id)
+	}
+	m.skip(now)
+	if id, _ := m.speaker(); id != 2 {
+		t.Errorf("Expected speaker 2 after skipping 1, got %d", id)
+	}
+	if !slices.Equal(m.queue, []uint64{2, 3, 1}) {
+		t.Errorf("Skipped speaker should go to the back, got %v", m.queue)
+	}
+	m.prev(now)
+	if _, ok := m.speaker(); ok {
+		t.Error("Going back from the first speaker should leave nobody speaking")
+	}
+	for range 5 {
+		m.next(now)
+	}
+	if _, ok := m.speaker(); ok || m.current != len(m.queue) {
+		t.Errorf("Meeting should stop after the last speaker, at %d", m.current)
+	}
+	if m.join(2) || !m.join(4) {
+		t.Error("Only new speakers should be added to the queue")
+	}
+}
+
+func TestMeetingRaisedHands(t *testing.T) {
+	now := time.Now()
+	m := newMeeting([]uint64{1, 2}, time.Minute)
+	m.raiseHand(2)
+	m.raiseHand(2)
+	if st := m.state(now); !slices.Equal(st.RaisedHands, []uint64{2}) {
+		t.Errorf("Expected one raised hand, got %v", st.RaisedHands)
+	}
+	m.next(now)
+	m.next(now)
+	if st := m.state(now); len(st.RaisedHands) != 0 || *st.CurrentSpeaker != 2 {
+		t.Errorf("Hand should drop once 2 speaks, got %+v", st)
+	}
+}
+
+func TestMeetingTimer(t *testing.T) {
+	now := time.Date(2024, 12, 30, 9, 0, 0, 0, time.UTC)
+	m := newMeeting([]uint64{1, 2}, time.Minute)
+	m.next(now)
+	m.timer.start(now)
+	now = now.Add(20 * time.Second)
+	m.timer.pause(now)
+	if st := m.state(now.Add(time.Hour)); st.Timer.RemainingMS != 40000 || st.Timer.Running {
+		t.Errorf("Paused timer should keep 40s left, got %+v", st.Timer)
+	}
+	m.timer.start(now)
+	st := m.state(now)
+	if st.Timer.Deadline == nil || !st.Timer.Deadline.Equal(now.Add(40*time.Second)) {
+		t.Errorf("Resumed timer should end 40s from now, got %+v", st.Timer)
+	}
+	m.next(now)
+	st = m.state(now)
+	if !st.Timer.Running || st.Timer.RemainingMS != 60000 {
+		t.Errorf("Next speaker should get a fresh running timer, got %+v", st.Timer)
+	}
+	m.timer.reset()
+	if st := m.state(now); st.Timer.Running || st.Timer.RemainingMS != 60000 {
+		t.Errorf("Reset timer should be stopped and full, got %+v", st.Timer)
+	}
+}
+
+func TestSpeakersFollowSeed(t *testing.T) {
+	var subs []user.DBUserSubmission
+	for id := uint64(1); id <= 8; id++ {
+		subs = append(subs, user.DBUserSubmission{UserSubmission: user.UserSubmission{UserId: id}})
+	}
+	first := speakers(subs, 42)
+	slices.Reverse(subs)
+	if again := speakers(subs, 42); !slices.Equal(first, again) {
+		t.Errorf("Same seed should give the same order whatever the input order, got %v and %v",
+			first, again)
+	}
+	if other := speakers(subs, 43); slices.Equal(first, other) {
+		t.Errorf("A new seed should change the order, got %v for both", first)
+	}
+}
+
+func TestOvertimeOncePerDeadline(t *testing.T) {
+	s := NewServer(nil, WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	c := connect(s, 9, 1)
+	s.joinMeeting(9, []uint64{1, 2}, 10*time.Millisecond, c)
+
+	s.sessions.Lock()
+	m := s.sessions.meetings[9]
+	now := time.Now()
+	m.next(now)
+	m.timer.start(now)
+	s.watchTimer(9, m, now)
+	// Commands that leave the timer alone still check it afterwards
+	m.raiseHand(2)
+	s.watchTimer(9, m, now)
+	m.lowerHand(2)
+	s.watchTimer(9, m, now)
+	s.sessions.Unlock()
+	time.Sleep(50 * time.Millisecond)
+
+	overtime := 0
+	for len(c.send) > 0 {
+		if o := <-c.send; o.Type == typeOvertime {
+			overtime++
+		}
+	}
+	if overtime != 1 {
+		t.Errorf("Expected one overtime event, got %d", overtime)
+	}
+}
@@ -2,7 +2,11 @@ package web
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"strings"
+
+	"github.com/dilithaw123/broccoli-backend/internal/user"
 )
 
 func (s *Server) MiddlewareLogIP(next http.Handler) http.Handler {
@@ -12,36 +16,86 @@ func (s *Server) MiddlewareLogIP(next http.Handler) http.Handler {
 	})
 }
 
+// Accepts an access token from the access_token cookie or an access token or
+// personal access token from an Authorization: Bearer header.
 func (s *Server) MiddlewareAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		authcookie, err := r.Cookie("access_token")
-		if err != nil || authcookie == nil {
-			s.logger.Debug("Error getting access token", "Error", err)
+		var tokenString string
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			tokenString = strings.TrimSpace(bearer)
+		} else if authcookie, err := r.Cookie(accessTokenCookie); err == nil {
+			tokenString = authcookie.Value
+		}
+		if tokenString == "" {
 			s.logger.Info("Unauthorized request", "ip", r.RemoteAddr)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
-		token, valid := ParseAndValidateToken(authcookie.Value, s.secretKey)
-		if !valid {
-			s.logger.Info(
-				"Unauthorized access token",
-				"ip",
-				r.RemoteAddr,
-				"token",
-				authcookie.Value,
-			)
+		var p Principal
+		var ok bool
+		if strings.HasPrefix(tokenString, user.AccessTokenPrefix) {
+			p, ok = s.authenticatePersonalAccessToken(r.Context(), tokenString)
+		} else {
+			p, ok = s.authenticateAccessToken(tokenString)
+		}
+		if !ok {
+			s.logger.Info("Unauthorized access token", "ip", r.RemoteAddr)
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
-		claims := token.Claims.(*CustomClaims)
-		switch {
-		case claims.Email == "":
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		r = r.WithContext(withPrincipal(r.Context(), p))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) authenticateAccessToken(tokenString string) (Principal, bool) {
+	token, valid := ParseAndValidateToken(tokenString, s.keys, s.tokenIssuer, s.tokenAudience)
+	if !valid {
+		return Principal{}, false
+	}
+	return token.Claims.(*CustomClaims).Principal()
+}
+
+func (s *Server) authenticatePersonalAccessToken(
+	ctx context.Context,
+	tokenString string,
+) (Principal, bool) {
+	pat, err := s.accessTokenService.UsePersonalAccessToken(ctx, user.HashToken(tokenString))
+	if err != nil {
+		if !errors.Is(err, user.ErrAccessTokenNotFound) {
+			s.logger.Error("Personal access token lookup", "Error", err)
+		}
+		return Principal{}, false
+	}
+	u, err := s.userService.GetUserByID(ctx, pat.UserID)
+	if err != nil {
+		return Principal{}, false
+	}
+	scopes := pat.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return Principal{ID: u.ID, Email: u.Email, Scopes: scopes}, true
+}
+
+// Rejects personal access tokens that were not granted scope
+func (s *Server) RequireScope(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !principal(r).HasScope(scope) {
+			http.Error(w, "token lacks scope "+scope, http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Only lets interactive logins through, never personal access tokens
+func (s *Server) RequireInteractive(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !principal(r).Interactive() {
+			http.Error(w, "not allowed with a personal access token", http.StatusForbidden)
 			return
 		}
-		ctx := r.Context()
-		ctx = context.WithValue(ctx, "email", claims.Email)
-		r = r.WithContext(ctx)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,139 @@
+package web
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/dilithaw123/broccoli-backend/internal/oidc"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	oidcStateCookie = "oidc_state"
+	oidcStatePath   = "/login/oidc"
+	oidcStateTTL    = 10 * time.Minute
+)
+
+// Everything the callback needs to finish the flow, kept client side in a
+// cookie signed by our keyring
+type oidcStateClaims struct {
+	jwt.RegisteredClaims
+	Provider string `json:"provider"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+func (s *Server) oidcStateAudience() string {
+	return s.tokenAudience + "/oidc-state"
+}
+
+// Redirects to the identity provider's login page
+func (s *Server) handleOIDCLogin() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := s.oidcProviders[r.PathValue("provider")]
+		if !ok {
+			http.Error(w, "unknown provider", http.StatusNotFound)
+			return
+		}
+		now := time.Now().UTC()
+		claims := oidcStateClaims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    s.tokenIssuer,
+				Audience:  jwt.ClaimStrings{s.oidcStateAudience()},
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(oidcStateTTL)),
+			},
+			Provider: provider.Name,
+			State:    oidc.RandomString(),
+			Nonce:    oidc.RandomString(),
+			Verifier: oidc.RandomString(),
+		}
+		authURL, err := provider.AuthCodeURL(
+			r.Context(),
+			claims.State,
+			claims.Nonce,
+			claims.Verifier,
+		)
+		if err != nil {
+			s.logger.Error("OIDC discovery", "provider", provider.Name, "Error", err)
+			http.Error(w, "identity provider unavailable", http.StatusBadGateway)
+			return
+		}
+		state, err := s.keys.Sign(claims)
+		if err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		// Lax so the cookie survives the top level redirect back from the provider
+		cookie := s.newCookie(oidcStateCookie, state, oidcStatePath, oidcStateTTL)
+		cookie.SameSite = http.SameSiteLaxMode
+		http.SetCookie(w, cookie)
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// Finishes the authorization code flow and logs the verified email in
+func (s *Server) handleOIDCCallback() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provider, ok := s.oidcProviders[r.PathValue("provider")]
+		if !ok {
+			http.Error(w, "unknown provider", http.StatusNotFound)
+			return
+		}
+		if errCode := r.URL.Query().Get("error"); errCode != "" {
+			s.logger.Info("OIDC login refused", "provider", provider.Name, "error", errCode)
+			http.Error(w, "login refused by identity provider", http.StatusUnauthorized)
+			return
+		}
+		cookie, err := r.Cookie(oidcStateCookie)
+		if err != nil {
+			http.Error(w, "missing login state", http.StatusBadRequest)
+			return
+		}
+		http.SetCookie(w, s.newCookie(oidcStateCookie, "", oidcStatePath, -1))
+		var state oidcStateClaims
+		_, err = jwt.ParseWithClaims(
+			cookie.Value,
+			&state,
+			s.keys.keyFunc,
+			jwt.WithIssuer(s.tokenIssuer),
+			jwt.WithAudience(s.oidcStateAudience()),
+			jwt.WithExpirationRequired(),
+		)
+		if err != nil ||
+			state.Provider != provider.Name ||
+			state.State != r.URL.Query().Get("state") {
+			http.Error(w, "invalid login state", http.StatusBadRequest)
+			return
+		}
+		claims, err := provider.Exchange(
+			r.Context(),
+			r.URL.Query().Get("code"),
+			state.Verifier,
+			state.Nonce,
+		)
+		if err != nil {
+			s.logger.Info("OIDC exchange failed", "provider", provider.Name, "Error", err)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if claims.Email == "" || !claims.EmailVerified || !provider.EmailAllowed(claims.Email) {
+			s.logger.Info("OIDC email rejected", "provider", provider.Name, "email", claims.Email)
+			http.Error(w, "email not allowed", http.StatusForbidden)
+			return
+		}
+		u, err := s.findOrCreateUser(r.Context(), claims.Email, claims.Name)
+		if err != nil {
+			s.logger.Error("OIDC login", "Error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if _, err := s.startSession(w, r, u); err != nil {
+			s.logger.Error("OIDC login", "Error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, s.postLoginURL, http.StatusFound)
+	}
+}
@@ -0,0 +1,40 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"slices"
+)
+
+// The authenticated caller, set on the request context by MiddlewareAuth.
+// Scopes is nil for interactive logins, which may do anything the user can.
+type Principal struct {
+	ID     uint64
+	Email  string
+	Scopes []string
+}
+
+func (p Principal) Interactive() bool {
+	return p.Scopes == nil
+}
+
+func (p Principal) HasScope(scope string) bool {
+	return p.Interactive() || slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+func withPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// Only valid behind MiddlewareAuth
+func principal(r *http.Request) Principal {
+	p, _ := PrincipalFromContext(r.Context())
+	return p
+}
@@ -0,0 +1,104 @@
+package web
+
+import (
+	_ "embed"
+	"encoding/json"
+	"net/http"
+)
+
+// Version of the session websocket protocol. Every message either way is an
+// envelope, and /ws/schema.json describes the payload of each type.
+const protocolVersion = 1
+
+// Clients ask for envelopes by offering this in Sec-WebSocket-Protocol. Those
+// that don't keep the original framing; see legacyFrame.
+const protocolName = "broccoli.v1"
+
+type envelope struct {
+	Type    string `json:"type"`
+	Version int    `json:"version"`
+	// Server messages count up from 1 on each connection. Clients number their
+	// own messages so they can tell which one an error is about.
+	Seq     uint64          `json:"seq"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Events the server sends
+const (
+	typeSubmissions  = "submissions"
+	typeUserChange   = "user_change"
+	typeMeetingState = "meeting_state"
+	typeSessionState = "session_state"
+	typeOvertime     = "overtime"
+	typeError        = "error"
+)
+
+// Announces that the user changed their submission. The meeting commands are
+// the other messages clients send.
+const commandUserChange = "user_change"
+
+// A server event before it is numbered for a connection
+type outgoing struct {
+	Type    string `json:"type"`
+	Payload any    `json:"payload"`
+}
+
+const (
+	errCodeInvalidMessage     = "invalid_message"
+	errCodeUnsupportedVersion = "unsupported_version"
+	errCodeUnknownCommand     = "unknown_command"
+	errCodeForbidden          = "forbidden"
+	errCodeInternal           = "internal"
+)
+
+// Sent in reply to a client message the server rejected
+type protocolError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Type and seq of the rejected message, when it had them
+	Command string `json:"command,omitempty"`
+	Seq     uint64 `json:"seq,omitempty"`
+}
+
+// Messages from clients that predate envelopes. A bare user_id announces a
+// changed submission.
+type clientMessage struct {
+	Command string `json:"command"`
+	UserId  uint64 `json:"user_id"`
+}
+
+// Events that carried their type inside the payload before envelopes
+var legacyTyped = map[string]bool{
+	typeMeetingState: true,
+	typeSessionState: true,
+	typeOvertime:     true,
+	typeError:        true,
+}
+
+// Writes an event the way clients without envelopes expect it: the bare
+// payload, with a type field added to the events that always had one.
+func legacyFrame(typ string, payload json.RawMessage) json.RawMessage {
+	if !legacyTyped[typ] {
+		return payload
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &fields); err != nil || fields == nil {
+		return payload
+	}
+	fields["type"], _ = json.Marshal(typ)
+	framed, err := json.Marshal(fields)
+	if err != nil {
+		return payload
+	}
+	return framed
+}
+
+//go:embed wsprotocol.schema.json
+var protocolSchema []byte
+
+func (s *Server) handleWSSchema() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/schema+json")
+		w.Write(protocolSchema)
+	}
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/coder/websocket"
+)
+
+// Collects every const and enum value in the schema
+func schemaValues(v any, out *[]string) {
+	switch v := v.(type) {
+	case map[string]any:
+		if c, ok := v["const"].(string); ok {
+			*out = append(*out, c)
+		}
+		if enum, ok := v["enum"].([]any); ok {
+			for _, e := range enum {
+				if s, ok := e.(string); ok {
+					*out = append(*out, s)
+				}
+			}
+		}
+		for _, child := range v {
+			schemaValues(child, out)
+		}
+	case []any:
+		for _, child := range v {
+			schemaValues(child, out)
+		}
+	}
+}
+
+func TestSchemaCoversProtocol(t *testing.T) {
+	var schema any
+	if err := json.Unmarshal(protocolSchema, &schema); err != nil {
+		t.Fatalf("Schema is not valid JSON: %v", err)
+	}
+	var values []string
+	schemaValues(schema, &values)
+	for _, name := range []string{
+		typeSubmissions, typeUserChange, typeMeetingState, typeSessionState, typeOvertime,
+		typeError, commandUserChange, commandNext, commandPrev, commandSkip, commandRaiseHand,
+		commandLowerHand, commandTimerStart, commandTimerPause, commandTimerReset,
+		errCodeInvalidMessage, errCodeUnsupportedVersion, errCodeUnknownCommand,
+		errCodeForbidden, errCodeInternal,
+	} {
+		if !slices.Contains(values, name) {
+			t.Errorf("Schema is missing %q", name)
+		}
+	}
+}
+
+func TestLegacyFrame(t *testing.T) {
+	for _, test := range []struct {
+		typ     string
+		payload string
+		want    string
+	}{
+		{typeSubmissions, `[{"user_id":1}]`, `[{"user_id":1}]`},
+		{typeUserChange, `{"user_id":1}`, `{"user_id":1}`},
+		{typeMeetingState, `{"position":0}`, `{"position":0,"type":"meeting_state"}`},
+		{typeError, `{"message":"no"}`, `{"message":"no","type":"error"}`},
+	} {
+		if got := string(legacyFrame(test.typ, json.RawMessage(test.payload))); got != test.want {
+			t.Errorf("%s: expected %s, got %s", test.typ, test.want, got)
+		}
+	}
+}
+
+func TestEnvelopesOnlyWhenNegotiated(t *testing.T) {
+	s := NewServer(nil, WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opts := &websocket.AcceptOptions{Subprotocols: []string{protocolName}}
+		conn, err := websocket.Accept(w, r, opts)
+		if err != nil {
+			return
+		}
+		send := make(chan outgoing, 1)
+		send <- outgoing{Type: typeSubmissions, Payload: []int{}}
+		close(send)
+		s.writeConn(conn, send, conn.Subprotocol() == protocolName)
+		conn.Read(context.Background())
+	}))
+	defer ts.Close()
+
+	for _, test := range []struct {
+		subprotocols []string
+		want         string
+	}{
+		{nil, `[]`},
+		{[]string{protocolName}, `{"type":"submissions","version":1,"seq":1,"payload":[]}`},
+	} {
+		ctx := context.Background()
+		opts := &websocket.DialOptions{Subprotocols: test.subprotocols}
+		conn, _, err := websocket.Dial(ctx, ts.URL, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, got, err := conn.Read(ctx)
+		conn.CloseNow()
+		if err != nil || string(got) != test.want+"\n" {
+			t.Errorf("%v: expected %s, got %s (%v)", test.subprotocols, test.want, got, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+	"github.com/dilithaw123/broccoli-backend/internal/user"
+)
+
+const (
+	// Messages a connection can fall behind by before it is dropped
+	sendQueueSize = 64
+	writeTimeout  = 10 * time.Second
+)
+
+// Sessions whose submissions changed since they were last pushed. Repeated
+// changes to a session before the push runs are sent once.
+type changeSet struct {
+	sync.Mutex
+	pending map[uint64]struct{}
+	wake    chan struct{}
+}
+
+func newChangeSet() *changeSet {
+	return &changeSet{pending: make(map[uint64]struct{}), wake: make(chan struct{}, 1)}
+}
+
+func (cs *changeSet) add(sessionId uint64) {
+	cs.Lock()
+	cs.pending[sessionId] = struct{}{}
+	cs.Unlock()
+	select {
+	case cs.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (cs *changeSet) take() []uint64 {
+	cs.Lock()
+	defer cs.Unlock()
+	ids := make([]uint64, 0, len(cs.pending))
+	for id := range cs.pending {
+		ids = append(ids, id)
+	}
+	clear(cs.pending)
+	return ids
+}
+
+// Marks the session's submissions as changed so its room gets them again on
+// every instance
+func (s *Server) submissionsChanged(sessionId uint64) {
+	s.publish(context.Background(), sessionId, eventSubmissions, nil)
+}
+
+// Sends each changed session's submissions to its connections on this instance
+func (s *Server) pushSubmissions() {
+	for range s.changes.wake {
+		for _, id := range s.changes.take() {
+			if !s.hasListeners(id) {
+				continue
+			}
+			ctx := context.Background()
+			sub, err := s.userService.GetAllUserSubmissionsForSession(ctx, id)
+			if err != nil {
+				s.logger.Error("Failed to get user submissions", "error", err, "sessionId", id)
+				continue
+			}
+			if sub == nil {
+				sub = []user.DBUserSubmission{}
+			}
+			s.deliver(id, outgoing{Type: typeSubmissions, Payload: sub})
+		}
+	}
+}
+
+func (s *Server) hasListeners(sessionId uint64) bool {
+	s.sessions.Lock()
+	defer s.sessions.Unlock()
+	return len(s.sessions.room[sessionId]) > 0
+}
+
+// Writes queued events to conn, numbered in order, until the queue is closed.
+// A failed write closes the connection, which ends its reader and removes it
+// from the room.
+func (s *Server) writeConn(conn *websocket.Conn, send <-chan outgoing, framed bool) {
+	var seq uint64
+	for o := range send {
+		payload, err := json.Marshal(o.Payload)
+		if err != nil {
+			s.logger.Error("Failed to encode event", "error", err, "type", o.Type)
+			continue
+		}
+		seq++
+		var msg any = envelope{Type: o.Type, Version: protocolVersion, Seq: seq, Payload: payload}
+		if !framed {
+			msg = legacyFrame(o.Type, payload)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), writeTimeout)
+		err = wsjson.Write(ctx, conn, msg)
+		cancel()
+		if err != nil {
+			s.logger.Error("Failed to write message", "error", err)
+			conn.Close(websocket.StatusInternalError, "write failed")
+			// Drain so senders never see a full queue while the room forgets us
+			for range send {
+			}
+			return
+		}
+	}
+}
+
+// Queues v for c without blocking. Must be called with s.sessions locked.
+// A client whose queue is full is too slow to keep up and is disconnected.
+func (s *Server) enqueue(sessionId uint64, conn *websocket.Conn, c client, o outgoing) {
+	select {
+	case c.send <- o:
+	default:
+		s.logger.Warn("Dropping slow websocket client", "sessionId", sessionId, "userId", c.userID)
+		s.dropConn(sessionId, conn)
+		go conn.Close(websocket.StatusPolicyViolation, "too slow")
+	}
+}
@@ -0,0 +1,66 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/dilithaw123/broccoli-backend/internal/user"
+)
+
+func TestChangeSetCoalesces(t *testing.T) {
+	cs := newChangeSet()
+	cs.add(1)
+	cs.add(2)
+	cs.add(1)
+	select {
+	case <-cs.wake:
+	default:
+		t.Fatal("Adding a change should wake the pusher")
+	}
+	ids := cs.take()
+	slices.Sort(ids)
+	if !slices.Equal(ids, []uint64{1, 2}) {
+		t.Errorf("Expected each session once, got %v", ids)
+	}
+	if ids := cs.take(); len(ids) != 0 {
+		t.Errorf("Expected no changes after take, got %v", ids)
+	}
+}
+
+// A session nobody has submitted to yet; methods the tests don't need are
+// left unimplemented
+type emptyUserService struct {
+	user.UserService
+}
+
+func (emptyUserService) GetAllUserSubmissionsForSession(
+	ctx context.Context,
+	sessionId uint64,
+) ([]user.DBUserSubmission, error) {
+	return nil, nil
+}
+
+func TestPushEmptySubmissions(t *testing.T) {
+	s := NewServer(
+		nil,
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		WithUserService(emptyUserService{}),
+	)
+	go s.pushSubmissions()
+	c := connect(s, 9, 1)
+	s.changes.add(9)
+	select {
+	case o := <-c.send:
+		data, _ := json.Marshal(o.Payload)
+		if o.Type != typeSubmissions || string(data) != "[]" {
+			t.Errorf("Expected an empty submissions array, got %s %s", o.Type, data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected submissions to be pushed")
+	}
+}
@@ -1,23 +1,162 @@
 package web
 
-import "net/http"
+import (
+	"net/http"
+
+	"github.com/dilithaw123/broccoli-backend/internal/session"
+	"github.com/dilithaw123/broccoli-backend/internal/user"
+)
 
 func (s *Server) Route() {
 	innerMux := http.NewServeMux()
-	innerMux.Handle("GET /ws/session/{id}", s.handleSessionWSConnection())
-	innerMux.Handle("POST /session/{id}/shuffle", s.handleShuffleSession())
-	innerMux.Handle("POST /session", s.handlePostSession())
-	innerMux.Handle("POST /group/user/add", s.handleAddUserToGroup())
-	innerMux.Handle("DELETE /group", s.handleDeleteGroup())
-	innerMux.Handle("POST /group", s.handlePostGroup())
-	innerMux.Handle("GET /user/submission", s.handleGetUserSubmission())
-	innerMux.Handle("POST /user/submission", s.handlePostUserSubmission())
-	innerMux.Handle("GET /user/group", s.handleGetUserGroups())
+	innerMux.Handle(
+		"GET /ws/session/{id}",
+		s.RequireScope(user.ScopeSessionsRead, s.handleSessionWSConnection()),
+	)
+	innerMux.Handle(
+		"POST /session/{id}/shuffle",
+		s.RequireScope(user.ScopeSessionsWrite, s.handleShuffleSession()),
+	)
+	for _, action := range []session.Action{
+		session.ActionStart,
+		session.ActionClose,
+		session.ActionReopen,
+		session.ActionLock,
+	} {
+		innerMux.Handle(
+			"POST /session/{id}/"+string(action),
+			s.RequireScope(user.ScopeSessionsWrite, s.handleSessionTransition(action)),
+		)
+	}
+	innerMux.Handle(
+		"POST /session/{id}/facilitator",
+		s.RequireScope(user.ScopeSessionsWrite, s.handleSetFacilitator()),
+	)
+	innerMux.Handle(
+		"GET /session/{id}",
+		s.RequireScope(user.ScopeSessionsRead, s.handleGetSession()),
+	)
+	innerMux.Handle(
+		"GET /group/{id}/sessions",
+		s.RequireScope(user.ScopeSessionsRead, s.handleGetGroupSessions()),
+	)
+	innerMux.Handle("POST /session", s.RequireScope(user.ScopeSessionsWrite, s.handlePostSession()))
+	innerMux.Handle(
+		"POST /group/user/add",
+		s.RequireScope(user.ScopeGroupsWrite, s.handleAddUserToGroup()),
+	)
+	innerMux.Handle(
+		"POST /group/user/role",
+		s.RequireScope(user.ScopeGroupsWrite, s.handleSetMemberRole()),
+	)
+	innerMux.Handle(
+		"GET /group/{id}/members",
+		s.RequireScope(user.ScopeGroupsRead, s.handleGetGroupMembers()),
+	)
+	innerMux.Handle(
+		"POST /group/user/remove",
+		s.RequireScope(user.ScopeGroupsWrite, s.handleRemoveUserFromGroup()),
+	)
+	innerMux.Handle(
+		"POST /group/{id}/leave",
+		s.RequireScope(user.ScopeGroupsWrite, s.handleLeaveGroup()),
+	)
+	innerMux.Handle(
+		"POST /group/{id}/transfer",
+		s.RequireScope(user.ScopeGroupsWrite, s.handleTransferOwnership()),
+	)
+	innerMux.Handle(
+		"GET /group/{id}/invites",
+		s.RequireScope(user.ScopeGroupsRead, s.handleGetGroupInvites()),
+	)
+	innerMux.Handle(
+		"POST /group/{id}/invites",
+		s.RequireScope(user.ScopeGroupsWrite, s.handlePostGroupInvite()),
+	)
+	innerMux.Handle(
+		"DELETE /group/{id}/invites/{invite_id}",
+		s.RequireScope(user.ScopeGroupsWrite, s.handleRevokeGroupInvite()),
+	)
+	innerMux.Handle("GET /group/{id}", s.RequireScope(user.ScopeGroupsRead, s.handleGetGroup()))
+	innerMux.Handle(
+		"PATCH /group/{id}",
+		s.RequireScope(user.ScopeGroupsWrite, s.handlePatchGroup()),
+	)
+	innerMux.Handle(
+		"GET /group/{id}/schedule",
+		s.RequireScope(user.ScopeGroupsRead, s.handleGetGroupSchedule()),
+	)
+	innerMux.Handle(
+		"PUT /group/{id}/schedule",
+		s.RequireScope(user.ScopeGroupsWrite, s.handlePutGroupSchedule()),
+	)
+	innerMux.Handle(
+		"DELETE /group/{id}/schedule",
+		s.RequireScope(user.ScopeGroupsWrite, s.handleDeleteGroupSchedule()),
+	)
+	innerMux.Handle(
+		"GET /group/{id}/skip-dates",
+		s.RequireScope(user.ScopeGroupsRead, s.handleGetSkipDates()),
+	)
+	innerMux.Handle(
+		"POST /group/{id}/skip-dates",
+		s.RequireScope(user.ScopeGroupsWrite, s.handlePostSkipDate()),
+	)
+	innerMux.Handle(
+		"POST /group/{id}/skip-dates/import",
+		s.RequireScope(user.ScopeGroupsWrite, s.handleImportSkipDates()),
+	)
+	innerMux.Handle(
+		"DELETE /group/{id}/skip-dates/{skip_id}",
+		s.RequireScope(user.ScopeGroupsWrite, s.handleDeleteSkipDate()),
+	)
+	innerMux.Handle("DELETE /group", s.RequireScope(user.ScopeGroupsWrite, s.handleDeleteGroup()))
+	innerMux.Handle("POST /group", s.RequireScope(user.ScopeGroupsWrite, s.handlePostGroup()))
+	innerMux.Handle(
+		"GET /user/submission",
+		s.RequireScope(user.ScopeSubmissionsRead, s.handleGetUserSubmission()),
+	)
+	innerMux.Handle(
+		"POST /user/submission",
+		s.RequireScope(user.ScopeSubmissionsWrite, s.handlePostUserSubmission()),
+	)
+	innerMux.Handle("GET /user/group", s.RequireScope(user.ScopeGroupsRead, s.handleGetUserGroups()))
+	innerMux.Handle(
+		"GET /user/invites",
+		s.RequireScope(user.ScopeGroupsRead, s.handleGetUserInvites()),
+	)
+	// Joining a group needs the user's own consent
+	innerMux.Handle("POST /invite/accept", s.RequireInteractive(s.handleAcceptInvite()))
+	innerMux.Handle("POST /invite/decline", s.RequireInteractive(s.handleDeclineInvite()))
 	innerMux.Handle("GET /user/authenticated", s.handleIsAuthorized())
+	// Account management is never available to personal access tokens
+	innerMux.Handle("GET /user/sessions", s.RequireInteractive(s.handleGetUserSessions()))
+	innerMux.Handle(
+		"POST /user/sessions/revoke-all",
+		s.RequireInteractive(s.handleRevokeAllUserSessions()),
+	)
+	innerMux.Handle(
+		"POST /user/sessions/{id}/revoke",
+		s.RequireInteractive(s.handleRevokeUserSession()),
+	)
+	innerMux.Handle("GET /user/tokens", s.RequireInteractive(s.handleGetAccessTokens()))
+	innerMux.Handle("POST /user/tokens", s.RequireInteractive(s.handlePostAccessToken()))
+	innerMux.Handle("DELETE /user/tokens/{id}", s.RequireInteractive(s.handleDeleteAccessToken()))
 	innerMux.Handle("GET /user", s.handleGetUser())
-	innerMux.Handle("POST /user", s.handlePostUser())
+	innerMux.Handle("POST /user", s.RequireInteractive(s.handlePostUser()))
 	// User without access token needs to be able to hit these endpoints
 	s.mux.Handle("POST /user/refresh", s.handleNewAccessToken())
-	s.mux.Handle("POST /login", s.MiddlewareAPIKey(s.handleLoginSignUp()))
+	s.mux.Handle("POST /user/logout", s.handleLogout())
+	// Kept for older clients; server issued refresh cookies are scoped to /user
+	s.mux.Handle("POST /logout", s.handleLogout())
+	s.mux.Handle("GET /.well-known/jwks.json", s.handleJWKS())
+	s.mux.Handle("GET /ws/schema.json", s.handleWSSchema())
+	s.mux.Handle("POST /login/magic", s.handleRequestMagicLink())
+	s.mux.Handle("POST /login/magic/verify", s.handleVerifyMagicLink())
+	s.mux.Handle("GET /login/oidc/{provider}", s.handleOIDCLogin())
+	s.mux.Handle("GET /login/oidc/{provider}/callback", s.handleOIDCCallback())
+	if s.apiKey != "" {
+		s.mux.Handle("POST /login", s.MiddlewareAPIKey(s.handleLoginSignUp()))
+	}
 	s.mux.Handle("/", s.MiddlewareAuth(innerMux))
 }
@@ -0,0 +1,106 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/dilithaw123/broccoli-backend/internal/group"
+)
+
+func (s *Server) handleGetGroupSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.authorizeGroup(w, r, id, group.PermRead); !ok {
+			return
+		}
+		sch, err := s.scheduleService.GetSchedule(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, group.ErrScheduleNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := respondJSON(w, http.StatusOK, sch); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// Replaces the group's schedule. Times are wall clock times in the group's
+// timezone and weekdays count from 0 for Sunday.
+func (s *Server) handlePutGroupSchedule() http.HandlerFunc {
+	type request struct {
+		Weekdays []int  `json:"weekdays"`
+		Time     string `json:"time"`
+		Enabled  *bool  `json:"enabled"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		slices.Sort(req.Weekdays)
+		sch := group.Schedule{
+			GroupID:  id,
+			Weekdays: slices.Compact(req.Weekdays),
+			Time:     req.Time,
+			Enabled:  req.Enabled == nil || *req.Enabled,
+		}
+		if err := sch.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.authorizeGroup(w, r, id, group.PermManageSettings); !ok {
+			return
+		}
+		sch, err = s.scheduleService.SetSchedule(r.Context(), sch)
+		if err != nil {
+			if errors.Is(err, group.ErrGroupNotFound) {
+				http.Error(w, "group not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := respondJSON(w, http.StatusOK, sch); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func (s *Server) handleDeleteGroupSchedule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.authorizeGroup(w, r, id, group.PermManageSettings); !ok {
+			return
+		}
+		if err := s.scheduleService.DeleteSchedule(r.Context(), id); err != nil {
+			if errors.Is(err, group.ErrScheduleNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
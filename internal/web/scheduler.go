@@ -0,0 +1,57 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dilithaw123/broccoli-backend/internal/session"
+)
+
+// Opens the sessions of scheduled groups as their standup time passes, so the
+// session and its carried over items exist before anyone joins.
+func (s *Server) runScheduler() {
+	ticker := time.NewTicker(s.schedulerInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.openScheduledSessions(context.Background(), now)
+	}
+}
+
+func (s *Server) openScheduledSessions(ctx context.Context, now time.Time) {
+	schedules, err := s.scheduleService.GetEnabledSchedules(ctx)
+	if err != nil {
+		s.logger.Error("Failed to get schedules", "error", err)
+		return
+	}
+	for _, sch := range schedules {
+		date, due := sch.Due(now)
+		if !due {
+			continue
+		}
+		claimed, err := s.scheduleService.ClaimScheduledRun(ctx, sch.GroupID, date)
+		if err != nil {
+			s.logger.Error("Failed to claim scheduled run", "error", err, "groupId", sch.GroupID)
+			continue
+		}
+		if !claimed {
+			// Another server got there first
+			continue
+		}
+		// CreateSession returns the day's session when one was already started by hand
+		id, err := s.sessionService.CreateSession(ctx, session.NewSession(sch.GroupID))
+		if errors.Is(err, session.ErrSkipDate) {
+			s.logger.Info("Skipped scheduled session", "groupId", sch.GroupID, "date", date)
+			continue
+		}
+		if err != nil {
+			s.logger.Error("Failed to open scheduled session", "error", err, "groupId", sch.GroupID)
+			err = s.scheduleService.ReleaseScheduledRun(ctx, sch.GroupID, date, sch.LastRunDate)
+			if err != nil {
+				s.logger.Error("Failed to release scheduled run", "error", err, "groupId", sch.GroupID)
+			}
+			continue
+		}
+		s.logger.Info("Opened scheduled session", "groupId", sch.GroupID, "sessionId", id, "date", date)
+	}
+}
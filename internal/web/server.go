@@ -1,46 +1,95 @@
 package web
 
 import (
+	"context"
 	"log/slog"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/coder/websocket"
+	"github.com/dilithaw123/broccoli-backend/internal/bus"
 	"github.com/dilithaw123/broccoli-backend/internal/group"
+	"github.com/dilithaw123/broccoli-backend/internal/mail"
+	"github.com/dilithaw123/broccoli-backend/internal/oidc"
 	"github.com/dilithaw123/broccoli-backend/internal/session"
 	"github.com/dilithaw123/broccoli-backend/internal/user"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-type room map[uint64]map[*websocket.Conn]struct{}
+// Who is on the other end of a websocket connection
+type client struct {
+	userID  uint64
+	email   string
+	groupID uint64
+	// As of connecting; commands look the role up again in case it changed
+	role group.Role
+	// Of the token the connection was opened with; nil for interactive logins
+	scopes []string
+	// Whether the connection negotiated envelopes rather than legacy framing
+	framed bool
+	// Outgoing messages, written by the connection's own goroutine
+	send chan outgoing
+}
+
+type room map[uint64]map[*websocket.Conn]client
 
 func newRoom() room {
-	return room(make(map[uint64]map[*websocket.Conn]struct{}))
+	return room(make(map[uint64]map[*websocket.Conn]client))
 }
 
+// Live connections and meeting state of every session with someone connected
 type sessionMap struct {
 	sync.Mutex
 	room
+	meetings map[uint64]*meeting
 }
 
 type Server struct {
-	db             *pgxpool.Pool
-	userService    user.UserService
-	groupService   group.GroupService
-	sessionService session.SessionService
-	mux            *http.ServeMux
-	logger         *slog.Logger
-	refTokenMap    map[string]string
-	secretKey      string
-	apiKey         string
-	sessions       sessionMap
+	db                  *pgxpool.Pool
+	userService         user.UserService
+	refreshTokenService user.RefreshTokenService
+	loginTokenService   user.LoginTokenService
+	accessTokenService  user.PersonalAccessTokenService
+	mailer              mail.Mailer
+	magicLinkURL        string
+	oidcProviders       map[string]*oidc.Provider
+	postLoginURL        string
+	groupService        group.GroupService
+	inviteService       group.InviteService
+	inviteURL           string
+	scheduleService     group.ScheduleService
+	schedulerInterval   time.Duration
+	skipDateService     group.SkipDateService
+	sessionService      session.SessionService
+	mux                 *http.ServeMux
+	logger              *slog.Logger
+	refreshTokenTTL     time.Duration
+	cookies             cookieOptions
+	keys                *Keyring
+	tokenIssuer         string
+	tokenAudience       string
+	apiKey              string
+	sessions            sessionMap
+	changes             *changeSet
+	bus                 bus.Bus
+	instanceID          string
 }
 
 func NewServer(db *pgxpool.Pool, opts ...BuilderOpts) *Server {
 	sessions := newRoom()
 	s := &Server{
-		refTokenMap: make(map[string]string),
-		sessions:    sessionMap{sync.Mutex{}, sessions},
+		refreshTokenTTL:   30 * 24 * time.Hour,
+		schedulerInterval: time.Minute,
+		oidcProviders:     make(map[string]*oidc.Provider),
+		postLoginURL:      "/",
+		tokenIssuer:       "broccoli-backend",
+		tokenAudience:     "broccoli",
+		cookies:           cookieOptions{Secure: true, SameSite: http.SameSiteLaxMode},
+		sessions:          sessionMap{sync.Mutex{}, sessions, make(map[uint64]*meeting)},
+		changes:           newChangeSet(),
+		bus:               bus.NewMemoryBus(),
+		instanceID:        newInstanceID(),
 	}
 	for _, opt := range opts {
 		opt(s)
@@ -56,6 +105,14 @@ func (s *Server) Start(port string) error {
 		Addr:    port,
 		Handler: handler,
 	}
-	go s.clientUpdate()
+	events, err := s.bus.Subscribe(context.Background())
+	if err != nil {
+		return err
+	}
+	go s.relayEvents(events)
+	go s.pushSubmissions()
+	if s.scheduleService != nil {
+		go s.runScheduler()
+	}
 	return server.ListenAndServe()
 }
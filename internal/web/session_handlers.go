@@ -1,11 +1,16 @@
 package web
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/dilithaw123/broccoli-backend/internal/group"
 	"github.com/dilithaw123/broccoli-backend/internal/session"
+	"github.com/dilithaw123/broccoli-backend/internal/user"
 )
 
 func (s *Server) handlePostSession() http.HandlerFunc {
@@ -18,20 +23,17 @@ func (s *Server) handlePostSession() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		email := r.Context().Value("email").(string)
-		exists, err := s.groupService.GroupContainsUser(r.Context(), req.GroupID, email)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		if !exists {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		if _, ok := s.authorizeGroup(w, r, req.GroupID, group.PermRunSession); !ok {
 			return
 		}
 
 		sess := session.NewSession(req.GroupID)
 		id, err := s.sessionService.CreateSession(r.Context(), sess)
 		if err != nil {
+			if errors.Is(err, session.ErrSkipDate) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -53,22 +55,207 @@ func (s *Server) handleShuffleSession() http.HandlerFunc {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		email := r.Context().Value("email").(string)
-		inSession, err := s.sessionService.UserInSession(r.Context(), id, email)
+		if _, _, ok := s.authorizeFacilitator(w, r, id); !ok {
+			return
+		}
+		newSeed := session.NewSeed()
+		err = s.sessionService.UpdateShuffle(r.Context(), id, newSeed)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		if !inSession {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		if err := s.resetMeeting(r.Context(), id); err != nil {
+			s.logger.Error("Failed to reset meeting", "error", err, "sessionId", id)
+		}
+		s.submissionsChanged(id)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// Moves the session through its lifecycle. Anyone who can run the standup can
+// start it, the facilitator closes it and reopening or locking a finished one
+// is for admins.
+func (s *Server) handleSessionTransition(action session.Action) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
-		newSeed := session.NewSeed()
-		err = s.sessionService.UpdateShuffle(r.Context(), id, newSeed)
+		var ok bool
+		switch action {
+		case session.ActionClose:
+			_, _, ok = s.authorizeFacilitator(w, r, id)
+		case session.ActionReopen, session.ActionLock:
+			_, _, ok = s.authorizeSession(w, r, id, group.PermManageSessions)
+		default:
+			_, _, ok = s.authorizeSession(w, r, id, group.PermRunSession)
+		}
+		if !ok {
+			return
+		}
+		sess, err := s.sessionService.TransitionSession(r.Context(), id, action)
 		if err != nil {
+			switch {
+			case errors.Is(err, session.ErrInvalidTransition):
+				http.Error(w, "cannot "+string(action)+" this session", http.StatusConflict)
+			case errors.Is(err, session.ErrSessionNotFound):
+				http.Error(w, "session not found", http.StatusNotFound)
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		s.sendSessionState(r.Context(), sess)
+		if err := respondJSON(w, http.StatusOK, sess); err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+const (
+	defaultSessionPageSize = 20
+	maxSessionPageSize     = 100
+)
+
+// Cursors are opaque to clients so the ordering can change without breaking them
+func encodeSessionCursor(id uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(id, 10)))
+}
+
+func decodeSessionCursor(cursor string) (uint64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(string(b), 10, 64)
+}
+
+// Lists a group's sessions, newest first. from and to are YYYY-MM-DD dates in
+// the group's timezone.
+func (s *Server) handleGetGroupSessions() http.HandlerFunc {
+	type response struct {
+		Sessions   []session.Session `json:"sessions"`
+		NextCursor string            `json:"next_cursor,omitempty"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
 			return
 		}
-		w.WriteHeader(http.StatusOK)
+		q := r.URL.Query()
+		opts := session.ListOptions{
+			From:  q.Get("from"),
+			To:    q.Get("to"),
+			Limit: defaultSessionPageSize,
+		}
+		for _, date := range []string{opts.From, opts.To} {
+			if _, err := time.Parse(time.DateOnly, date); date != "" && err != nil {
+				http.Error(w, "from and to must be YYYY-MM-DD", http.StatusBadRequest)
+				return
+			}
+		}
+		if limit := q.Get("limit"); limit != "" {
+			opts.Limit, err = strconv.Atoi(limit)
+			if err != nil || opts.Limit < 1 || opts.Limit > maxSessionPageSize {
+				http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+				return
+			}
+		}
+		if cursor := q.Get("cursor"); cursor != "" {
+			if opts.BeforeID, err = decodeSessionCursor(cursor); err != nil {
+				http.Error(w, "invalid cursor", http.StatusBadRequest)
+				return
+			}
+		}
+		if _, ok := s.authorizeGroup(w, r, id, group.PermRead); !ok {
+			return
+		}
+		// One extra row tells us whether there is another page
+		limit := opts.Limit
+		opts.Limit++
+		sessions, err := s.sessionService.ListSessions(r.Context(), id, opts)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		resp := response{Sessions: sessions}
+		if len(sessions) > limit {
+			resp.Sessions = sessions[:limit]
+			resp.NextCursor = encodeSessionCursor(resp.Sessions[limit-1].ID)
+		}
+		if err := respondJSON(w, http.StatusOK, resp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func (s *Server) handleGetSession() http.HandlerFunc {
+	type response struct {
+		session.Session
+		Submissions []user.DBUserSubmission `json:"submissions"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		sess, _, ok := s.authorizeSession(w, r, id, group.PermRead)
+		if !ok {
+			return
+		}
+		subs, err := s.userService.GetAllUserSubmissionsForSession(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if subs == nil {
+			subs = []user.DBUserSubmission{}
+		}
+		if err := respondJSON(w, http.StatusOK, response{Session: sess, Submissions: subs}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// Hands the session over to another member. The current facilitator and
+// admins can do this.
+func (s *Server) handleSetFacilitator() http.HandlerFunc {
+	type request struct {
+		Email string `json:"email"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if _, _, ok := s.authorizeFacilitator(w, r, id); !ok {
+			return
+		}
+		if err := s.sessionService.SetFacilitator(r.Context(), id, req.Email); err != nil {
+			if errors.Is(err, session.ErrInvalidFacilitator) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sess, err := s.sessionService.GetSession(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.sendSessionState(r.Context(), sess)
+		if err := respondJSON(w, http.StatusOK, sess); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
 	}
 }
@@ -0,0 +1,125 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dilithaw123/broccoli-backend/internal/group"
+)
+
+// Largest ICS file accepted by handleImportSkipDates
+const maxICSSize = 1 << 20
+
+func (s *Server) handleGetSkipDates() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.authorizeGroup(w, r, id, group.PermRead); !ok {
+			return
+		}
+		dates, err := s.skipDateService.GetSkipDates(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := respondJSON(w, http.StatusOK, dates); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+func (s *Server) handlePostSkipDate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		var req group.SkipDate
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Recurrence == "" {
+			req.Recurrence = group.RecurrenceOnce
+		}
+		if err := req.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.addSkipDates(w, r, id, []group.SkipDate{req})
+	}
+}
+
+// Imports the events of an iCalendar file, such as a public holiday feed, as
+// skip dates. Dates the group already skips are left alone.
+func (s *Server) handleImportSkipDates() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		dates, err := group.ParseICS(http.MaxBytesReader(w, r.Body, maxICSSize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.addSkipDates(w, r, id, dates)
+	}
+}
+
+func (s *Server) addSkipDates(
+	w http.ResponseWriter,
+	r *http.Request,
+	groupID uint64,
+	dates []group.SkipDate,
+) {
+	if _, ok := s.authorizeGroup(w, r, groupID, group.PermManageSettings); !ok {
+		return
+	}
+	added, err := s.skipDateService.AddSkipDates(r.Context(), groupID, dates)
+	if err != nil {
+		if errors.Is(err, group.ErrGroupNotFound) {
+			http.Error(w, "group not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := respondJSON(w, http.StatusCreated, added); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *Server) handleDeleteSkipDate() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		skipID, err := strconv.ParseUint(r.PathValue("skip_id"), 10, 64)
+		if err != nil {
+			http.Error(w, "skip_id must be an integer", http.StatusBadRequest)
+			return
+		}
+		if _, ok := s.authorizeGroup(w, r, id, group.PermManageSettings); !ok {
+			return
+		}
+		if err := s.skipDateService.DeleteSkipDate(r.Context(), id, skipID); err != nil {
+			if errors.Is(err, group.ErrSkipDateNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,98 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dilithaw123/broccoli-backend/internal/user"
+)
+
+// Creates a personal access token. The token is only ever shown in this response.
+func (s *Server) handlePostAccessToken() http.HandlerFunc {
+	type request struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	type response struct {
+		user.PersonalAccessToken
+		Token string `json:"token"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if req.Name == "" {
+			http.Error(w, "name is required", http.StatusBadRequest)
+			return
+		}
+		if len(req.Scopes) == 0 {
+			http.Error(w, "at least one scope is required", http.StatusBadRequest)
+			return
+		}
+		for _, scope := range req.Scopes {
+			if !user.ValidScope(scope) {
+				http.Error(w, "unknown scope "+scope, http.StatusBadRequest)
+				return
+			}
+		}
+		if req.ExpiresInDays < 0 {
+			http.Error(w, "expires_in_days must not be negative", http.StatusBadRequest)
+			return
+		}
+		token, pat := user.NewPersonalAccessToken(
+			principal(r).ID,
+			req.Name,
+			req.Scopes,
+			time.Duration(req.ExpiresInDays)*24*time.Hour,
+		)
+		pat, err := s.accessTokenService.CreatePersonalAccessToken(r.Context(), pat)
+		if err != nil {
+			s.logger.Error("Create access token", "Error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		resp := response{PersonalAccessToken: pat, Token: token}
+		if err := respondJSON(w, http.StatusCreated, resp); err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func (s *Server) handleGetAccessTokens() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokens, err := s.accessTokenService.GetPersonalAccessTokens(r.Context(), principal(r).ID)
+		if err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		if err := respondJSON(w, http.StatusOK, tokens); err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+func (s *Server) handleDeleteAccessToken() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
+			return
+		}
+		err = s.accessTokenService.RevokePersonalAccessToken(r.Context(), principal(r).ID, id)
+		if err != nil {
+			if errors.Is(err, user.ErrAccessTokenNotFound) {
+				http.Error(w, "token not found", http.StatusNotFound)
+				return
+			}
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -1,12 +1,15 @@
 package web
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"net/http"
 	"strconv"
 	"strings"
 
+	"github.com/dilithaw123/broccoli-backend/internal/group"
+	"github.com/dilithaw123/broccoli-backend/internal/types"
 	"github.com/dilithaw123/broccoli-backend/internal/user"
 )
 
@@ -75,27 +78,83 @@ func (s *Server) handlePostUser() http.HandlerFunc {
 	}
 }
 
+// Writes the caller's own submission. Writing someone else's requires
+// on_behalf_of and is for the session's facilitator and admins. The caller is
+// then recorded as its editor.
 func (s *Server) handlePostUserSubmission() http.HandlerFunc {
+	type request struct {
+		user.UserSubmission
+		OnBehalfOf bool `json:"on_behalf_of"`
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		var sub user.UserSubmission
-		if err := json.NewDecoder(r.Body).Decode(&sub); err != nil {
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "invalid JSON", http.StatusBadRequest)
 			return
 		}
-		email := r.Context().Value("email").(string)
-		exists, err := s.sessionService.UserInSession(r.Context(), sub.SessionId, email)
-		if err != nil {
-			http.Error(w, "internal server error", http.StatusInternalServerError)
+		p := principal(r)
+		sub := req.UserSubmission
+		sub.EditedBy = nil
+		if sub.UserId == 0 {
+			sub.UserId = p.ID
+		}
+		onBehalf := sub.UserId != p.ID
+		if onBehalf && !req.OnBehalfOf {
+			http.Error(w, "cannot edit another user's submission", http.StatusForbidden)
 			return
 		}
-		if !exists {
-			http.Error(w, "forbidden", http.StatusForbidden)
+		perm := group.PermSubmit
+		if onBehalf {
+			perm = group.PermRead
+		}
+		sess, role, ok := s.authorizeSession(w, r, sub.SessionId, perm)
+		if !ok {
+			return
+		}
+		if onBehalf {
+			if !canEditOthers(sess, p.Email, role) {
+				http.Error(w, "only the facilitator can edit on behalf of others", http.StatusForbidden)
+				return
+			}
+			owner, err := s.userService.GetUserByID(r.Context(), sub.UserId)
+			if err != nil {
+				if errors.Is(err, user.ErrUserNotFound) {
+					http.Error(w, "user not found", http.StatusNotFound)
+					return
+				}
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			ownerRole, err := s.groupService.GetMemberRole(r.Context(), sess.GroupID, owner.Email)
+			if err != nil && !errors.Is(err, group.ErrNotMember) {
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+			if !ownerRole.Can(group.PermSubmit) {
+				http.Error(w, "user not in session", http.StatusForbidden)
+				return
+			}
+			sub.EditedBy = &p.ID
+			s.logger.Info(
+				"Submission edited on behalf of user",
+				"sessionId", sub.SessionId,
+				"userId", sub.UserId,
+				"editorId", p.ID,
+			)
+		}
+		if !sess.State.AcceptsSubmissions() {
+			http.Error(w, user.ErrSubmissionsLocked.Error(), http.StatusConflict)
 			return
 		}
 		if err := s.userService.CreateUpdateUserSubmission(r.Context(), sub); err != nil {
+			if errors.Is(err, user.ErrSubmissionsLocked) {
+				http.Error(w, err.Error(), http.StatusConflict)
+				return
+			}
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 			return
 		}
+		s.submissionsChanged(sub.SessionId)
 	}
 }
 
@@ -106,14 +165,7 @@ func (s *Server) handleGetUserSubmission() http.HandlerFunc {
 			http.Error(w, "missing session_id parameter", http.StatusBadRequest)
 			return
 		}
-		email := r.Context().Value("email").(string)
-		exists, err := s.sessionService.UserInSession(r.Context(), sessionId, email)
-		if err != nil {
-			http.Error(w, "internal server error", http.StatusInternalServerError)
-			return
-		}
-		if !exists {
-			http.Error(w, "forbidden", http.StatusForbidden)
+		if _, _, ok := s.authorizeSession(w, r, sessionId, group.PermRead); !ok {
 			return
 		}
 		var sub interface{}
@@ -152,47 +204,77 @@ func (s *Server) handleLoginSignUp() http.HandlerFunc {
 		Email string `json:"email"`
 	}
 
-	type loginResponse struct {
-		User         user.User `json:"user"`
-		RefreshToken string    `json:"refresh_token"`
-		AccessToken  string    `json:"access_token"`
-	}
-
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req loginRequest
 		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 			http.Error(w, "invalid JSON", http.StatusBadRequest)
 			return
 		}
-		req.Email = strings.ToLower(req.Email)
-		u, err := s.userService.GetUserByEmail(r.Context(), req.Email)
-		if err != nil && !errors.Is(err, user.ErrUserNotFound) {
+		u, err := s.findOrCreateUser(r.Context(), req.Email, req.Name)
+		if err != nil {
 			s.logger.Error("Login/SignUp", "Error", err)
 			http.Error(w, "internal server error", http.StatusInternalServerError)
 			return
 		}
-		if errors.Is(err, user.ErrUserNotFound) {
-			u = user.NewUser(req.Name, req.Email)
-			if u, err = s.userService.CreateUser(r.Context(), u); err != nil {
-				http.Error(w, "internal server error", http.StatusInternalServerError)
-				return
-			}
-		}
-		w.Header().Set("Content-Type", "application/json")
-		refreshToken := GenerateRefreshToken()
-		accessToken := GenerateAccessToken(u.Email, s.secretKey)
-		s.refTokenMap[u.Email] = refreshToken
-		resp := loginResponse{
-			User:         u,
-			RefreshToken: refreshToken,
-			AccessToken:  accessToken,
-		}
-		if err := json.NewEncoder(w).Encode(resp); err != nil {
-			http.Error(w, "internal server error", http.StatusInternalServerError)
-		}
+		s.completeLogin(w, r, u)
+	}
+}
+
+// The tokens are only filled in when the server is configured with
+// WithTokensInBody; otherwise they travel in the auth cookies alone.
+type loginResponse struct {
+	User         user.User `json:"user"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	AccessToken  string    `json:"access_token,omitempty"`
+}
+
+// Looks the user up by email, creating them on their first login
+func (s *Server) findOrCreateUser(ctx context.Context, email, name string) (user.User, error) {
+	email = strings.ToLower(email)
+	u, err := s.userService.GetUserByEmail(ctx, email)
+	if errors.Is(err, user.ErrUserNotFound) {
+		return s.userService.CreateUser(ctx, user.NewUser(name, email))
+	}
+	return u, err
+}
+
+// Issues a token pair for u, sets the auth cookies and writes the login response
+func (s *Server) completeLogin(w http.ResponseWriter, r *http.Request, u user.User) {
+	resp, err := s.startSession(w, r, u)
+	if err != nil {
+		s.logger.Error("Login/SignUp", "Error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
 	}
 }
 
+// Issues a token pair for u and sets the auth cookies
+func (s *Server) startSession(
+	w http.ResponseWriter,
+	r *http.Request,
+	u user.User,
+) (loginResponse, error) {
+	refreshToken, err := s.issueRefreshToken(r, u.ID)
+	if err != nil {
+		return loginResponse{}, err
+	}
+	accessToken, err := GenerateAccessToken(u, s.keys, s.tokenIssuer, s.tokenAudience)
+	if err != nil {
+		return loginResponse{}, err
+	}
+	s.setAuthCookies(w, accessToken, refreshToken)
+	resp := loginResponse{User: u}
+	if s.cookies.TokensInBody {
+		resp.RefreshToken = refreshToken
+		resp.AccessToken = accessToken
+	}
+	return resp, nil
+}
+
 // Sends ok response. If it reaches this endpoint this must mean the middleware has authenticated the user.
 func (s *Server) handleIsAuthorized() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -200,33 +282,156 @@ func (s *Server) handleIsAuthorized() http.HandlerFunc {
 	}
 }
 
-// If user's email exists in map with matching refresh token, send a new access token
+// Exchanges the refresh_token cookie for a new access token. The refresh token
+// is rotated on every call; replaying a rotated token revokes the whole family.
 func (s *Server) handleNewAccessToken() http.HandlerFunc {
-	type request struct {
-		Email string `json:"email"`
-	}
 	type response struct {
-		AccessToken string `json:"access_token"`
+		AccessToken  string `json:"access_token,omitempty"`
+		RefreshToken string `json:"refresh_token,omitempty"`
 	}
 	return func(w http.ResponseWriter, r *http.Request) {
-		var req request
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "bad request", http.StatusBadRequest)
-			return
-		}
-		req.Email = strings.ToLower(req.Email)
-		refreshToken, err := r.Cookie("refresh_token")
+		refreshToken, err := r.Cookie(refreshTokenCookie)
 		if err != nil || refreshToken.Value == "" {
 			http.Error(w, "forbidden", http.StatusForbidden)
 			return
 		}
-		if val, ok := s.refTokenMap[req.Email]; !ok || refreshToken.Value != val {
-			http.Error(w, "forbidden", http.StatusForbidden)
+		token, next := user.NewRefreshToken(0, "", r.UserAgent(), clientIP(r), s.refreshTokenTTL)
+		rotated, err := s.refreshTokenService.RotateRefreshToken(
+			r.Context(),
+			user.HashToken(refreshToken.Value),
+			next,
+		)
+		if err != nil {
+			switch {
+			case errors.Is(err, user.ErrRefreshTokenReused):
+				s.logger.Warn("Refresh token reuse detected", "ip", r.RemoteAddr)
+				http.Error(w, "forbidden", http.StatusForbidden)
+			case errors.Is(err, user.ErrRefreshTokenNotFound),
+				errors.Is(err, user.ErrRefreshTokenExpired):
+				http.Error(w, "forbidden", http.StatusForbidden)
+			default:
+				s.logger.Error("Refresh access token", "Error", err)
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+		u, err := s.userService.GetUserByID(r.Context(), rotated.UserID)
+		if err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		accessToken, err := GenerateAccessToken(u, s.keys, s.tokenIssuer, s.tokenAudience)
+		if err != nil {
+			s.logger.Error("Refresh access token", "Error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		s.setAuthCookies(w, accessToken, token)
+		var resp response
+		if s.cookies.TokensInBody {
+			resp = response{AccessToken: accessToken, RefreshToken: token}
+		}
+		if err := respondJSON(w, http.StatusOK, resp); err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// Revokes the caller's refresh token and clears the auth cookies. The access
+// token may already be expired, so this only relies on the refresh cookie.
+func (s *Server) handleLogout() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		refreshToken, err := r.Cookie(refreshTokenCookie)
+		if err == nil && refreshToken.Value != "" {
+			err = s.refreshTokenService.RevokeRefreshToken(
+				r.Context(),
+				user.HashToken(refreshToken.Value),
+			)
+			if err != nil && !errors.Is(err, user.ErrRefreshTokenNotFound) {
+				s.logger.Error("Logout", "Error", err)
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+				return
+			}
+		}
+		s.clearAuthCookies(w)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Lists the devices the caller is logged in on
+func (s *Server) handleGetUserSessions() http.HandlerFunc {
+	type device struct {
+		ID        uint64           `json:"id"`
+		UserAgent string           `json:"user_agent"`
+		IP        string           `json:"ip"`
+		LastUsed  types.CustomTime `json:"last_used"`
+		Current   bool             `json:"current"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		tokens, err := s.refreshTokenService.GetActiveRefreshTokens(r.Context(), principal(r).ID)
+		if err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		var currentHash string
+		if c, err := r.Cookie(refreshTokenCookie); err == nil {
+			currentHash = user.HashToken(c.Value)
+		}
+		devices := make([]device, 0, len(tokens))
+		for _, t := range tokens {
+			devices = append(devices, device{
+				ID:        t.ID,
+				UserAgent: t.UserAgent,
+				IP:        t.IP,
+				LastUsed:  t.LastUsed,
+				Current:   t.TokenHash == currentHash,
+			})
+		}
+		if err := respondJSON(w, http.StatusOK, devices); err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	}
+}
+
+// Logs a single device out
+func (s *Server) handleRevokeUserSession() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.ParseUint(r.PathValue("id"), 10, 64)
+		if err != nil {
+			http.Error(w, "id must be an integer", http.StatusBadRequest)
 			return
 		}
-		resp := response{AccessToken: GenerateAccessToken(req.Email, s.secretKey)}
-		if err := json.NewEncoder(w).Encode(resp); err != nil {
+		err = s.refreshTokenService.RevokeRefreshTokenByID(r.Context(), principal(r).ID, id)
+		if err != nil {
+			if errors.Is(err, user.ErrRefreshTokenNotFound) {
+				http.Error(w, "session not found", http.StatusNotFound)
+				return
+			}
 			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
 		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Logs the caller out of every device, including this one
+func (s *Server) handleRevokeAllUserSessions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := s.refreshTokenService.RevokeAllRefreshTokens(r.Context(), principal(r).ID)
+		if err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		s.clearAuthCookies(w)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Creates and stores a refresh token for the requesting device
+func (s *Server) issueRefreshToken(r *http.Request, userID uint64) (string, error) {
+	token, rt := user.NewRefreshToken(userID, "", r.UserAgent(), clientIP(r), s.refreshTokenTTL)
+	if _, err := s.refreshTokenService.CreateRefreshToken(r.Context(), rt); err != nil {
+		return "", err
 	}
+	return token, nil
 }
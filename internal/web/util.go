@@ -2,6 +2,7 @@ package web
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 )
 
@@ -10,3 +11,11 @@ func respondJSON(w http.ResponseWriter, code int, data interface{}) error {
 	w.WriteHeader(code)
 	return json.NewEncoder(w).Encode(data)
 }
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
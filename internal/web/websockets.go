@@ -3,18 +3,28 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
 	"time"
 
 	"github.com/coder/websocket"
-	"github.com/coder/websocket/wsjson"
+	"github.com/dilithaw123/broccoli-backend/internal/group"
+	"github.com/dilithaw123/broccoli-backend/internal/session"
+	"github.com/dilithaw123/broccoli-backend/internal/user"
 )
 
 type userChange struct {
 	UserId uint64 `json:"user_id"`
 }
 
+// Sent to the room whenever the session is started, closed, reopened or locked
+type sessionStateChange struct {
+	Session session.Session `json:"session"`
+}
+
 func (s *Server) handleSessionWSConnection() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		session := r.PathValue("id")
@@ -24,45 +34,189 @@ func (s *Server) handleSessionWSConnection() http.HandlerFunc {
 			http.Error(w, "missing session_id parameter", http.StatusBadRequest)
 			return
 		}
-		email := r.Context().Value("email").(string)
-		exists, err := s.sessionService.UserInSession(r.Context(), sessionId, email)
-		if err != nil {
-			s.logger.Error("Failed to check if user is in session", "error", err)
-			http.Error(w, "internal server error", http.StatusInternalServerError)
-			return
-		}
-		if !exists {
-			s.logger.Info("User not in session", "sessionId", sessionId, "email", email)
-			http.Error(w, "forbidden", http.StatusForbidden)
+		sess, role, ok := s.authorizeSession(w, r, sessionId, group.PermRead)
+		if !ok {
 			return
 		}
 		conn, err := websocket.Accept(
 			w,
 			r,
-			&websocket.AcceptOptions{OriginPatterns: []string{"broccoli.buzz"}},
+			&websocket.AcceptOptions{
+				OriginPatterns: []string{"broccoli.buzz"},
+				Subprotocols:   []string{protocolName},
+			},
 		)
 		if err != nil {
 			s.logger.Error("Failed to upgrade connection", "error", err)
 			return
 		}
 		s.logger.Info("New websocket connection", "ip", r.RemoteAddr)
-		s.addToSessionMap(sessionId, conn)
-		go s.readConn(context.Background(), sessionId, conn)
+		p := principal(r)
+		c := client{
+			userID:  p.ID,
+			email:   p.Email,
+			groupID: sess.GroupID,
+			role:    role,
+			scopes:  p.Scopes,
+			framed:  conn.Subprotocol() == protocolName,
+		}
+		ctx := context.Background()
+		subs, err := s.userService.GetAllUserSubmissionsForSession(ctx, sessionId)
+		if err != nil {
+			s.logger.Error("Failed to get user submissions", "error", err, "sessionId", sessionId)
+			conn.Close(websocket.StatusInternalError, "internal server error")
+			return
+		}
+		if subs == nil {
+			subs = []user.DBUserSubmission{}
+		}
+		g, err := s.groupService.GetGroup(ctx, sess.GroupID)
+		if err != nil {
+			s.logger.Error("Failed to get group", "error", err, "groupId", sess.GroupID)
+			conn.Close(websocket.StatusInternalError, "internal server error")
+			return
+		}
+		speakerTime := time.Duration(g.SpeakerSeconds) * time.Second
+		c.send = make(chan outgoing, sendQueueSize)
+		go s.writeConn(conn, c.send, c.framed)
+		s.addToSessionMap(sessionId, conn, c)
+		queue := speakers(subs, sess.ShuffleSeed)
+		state, changed, created := s.joinMeeting(sessionId, queue, speakerTime, c)
+		// Late joiners get the room as it is rather than waiting for a change
+		s.sendTo(sessionId, conn, outgoing{Type: typeSubmissions, Payload: subs})
+		s.sendTo(sessionId, conn, outgoing{Type: typeMeetingState, Payload: state})
+		s.announceJoin(ctx, sessionId, state, changed, created)
+		go s.readConn(ctx, sessionId, conn, c.framed)
 	}
 }
 
-func (s *Server) sendUserChange(ctx context.Context, sessionId uint64, userId uint64) error {
+// A meeting that was just started here may be further along on another
+// instance, so rather than overwrite it with a fresh one, ask for it. The
+// joiner is merged in when it arrives; see adoptMeeting.
+func (s *Server) announceJoin(
+	ctx context.Context,
+	sessionId uint64,
+	state meetingState,
+	changed, created bool,
+) {
+	switch {
+	case created:
+		s.publish(ctx, sessionId, eventMeetingSync, nil)
+	case changed:
+		s.publish(ctx, sessionId, eventMeeting, state)
+	}
+}
+
+// The speaking order is the session's submitters, shuffled by its seed
+func speakers(subs []user.DBUserSubmission, seed uint16) []uint64 {
+	queue := make([]uint64, len(subs))
+	for i, sub := range subs {
+		queue[i] = sub.UserId
+	}
+	slices.Sort(queue)
+	session.Shuffle(seed, len(queue), func(i, j int) {
+		queue[i], queue[j] = queue[j], queue[i]
+	})
+	return queue
+}
+
+// Starts the session's meeting from queue if it isn't running yet and adds c
+// to the speakers when they can take part. Reports whether the queue changed
+// and whether the meeting was started.
+func (s *Server) joinMeeting(
+	sessionId uint64,
+	queue []uint64,
+	speakerTime time.Duration,
+	c client,
+) (meetingState, bool, bool) {
 	s.sessions.Lock()
 	defer s.sessions.Unlock()
-	for conn := range s.sessions.room[sessionId] {
-		if err := wsjson.Write(ctx, conn, userChange{UserId: userId}); err != nil {
-			continue
+	m, ok := s.sessions.meetings[sessionId]
+	if !ok {
+		m = newMeeting(queue, speakerTime)
+		s.sessions.meetings[sessionId] = m
+	}
+	changed := c.role.Can(group.PermSubmit) && m.join(c.userID)
+	return m.state(time.Now()), changed, !ok
+}
+
+// Starts the meeting over with a new speaking order, after a reshuffle
+func (s *Server) resetMeeting(ctx context.Context, sessionId uint64) error {
+	sess, err := s.sessionService.GetSession(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+	subs, err := s.userService.GetAllUserSubmissionsForSession(ctx, sessionId)
+	if err != nil {
+		return err
+	}
+	queue := speakers(subs, sess.ShuffleSeed)
+	s.sessions.Lock()
+	old, ok := s.sessions.meetings[sessionId]
+	if !ok {
+		s.sessions.Unlock()
+		return nil
+	}
+	m := newMeeting(queue, old.timer.length)
+	for _, c := range s.sessions.room[sessionId] {
+		if c.role.Can(group.PermSubmit) {
+			m.join(c.userID)
 		}
 	}
+	s.sessions.meetings[sessionId] = m
+	state := m.state(time.Now())
+	s.sessions.Unlock()
+	s.publish(ctx, sessionId, eventMeeting, state)
 	return nil
 }
 
-func (s *Server) readConn(ctx context.Context, sessionId uint64, conn *websocket.Conn) {
+func (s *Server) sendUserChange(ctx context.Context, sessionId uint64, userId uint64) error {
+	s.broadcast(ctx, sessionId, outgoing{Type: typeUserChange, Payload: userChange{UserId: userId}})
+	return nil
+}
+
+func (s *Server) sendSessionState(ctx context.Context, sess session.Session) {
+	s.broadcast(ctx, sess.ID, outgoing{
+		Type:    typeSessionState,
+		Payload: sessionStateChange{Session: sess},
+	})
+}
+
+// Sends o to every connection to the session, on every instance
+func (s *Server) broadcast(ctx context.Context, sessionId uint64, o outgoing) {
+	s.publish(ctx, sessionId, eventMessage, o)
+}
+
+// Queues o for a single connection, if it is still in the room
+func (s *Server) sendTo(sessionId uint64, conn *websocket.Conn, o outgoing) {
+	s.sessions.Lock()
+	defer s.sessions.Unlock()
+	if c, ok := s.sessions.room[sessionId][conn]; ok {
+		s.enqueue(sessionId, conn, c, o)
+	}
+}
+
+// Tells conn why its message was not accepted
+func (s *Server) reject(
+	sessionId uint64,
+	conn *websocket.Conn,
+	env envelope,
+	code, message string,
+) {
+	s.sendTo(sessionId, conn, outgoing{Type: typeError, Payload: protocolError{
+		Code:    code,
+		Message: message,
+		Command: env.Type,
+		Seq:     env.Seq,
+	}})
+}
+
+func (s *Server) readConn(
+	ctx context.Context,
+	sessionId uint64,
+	conn *websocket.Conn,
+	framed bool,
+) {
 	for {
 		_, bytes, err := conn.Read(ctx)
 		if err != nil {
@@ -71,56 +225,258 @@ func (s *Server) readConn(ctx context.Context, sessionId uint64, conn *websocket
 			s.removeFromSessionMap(sessionId, conn)
 			return
 		}
-		var v userChange
-		if err := json.Unmarshal(bytes, &v); err == nil {
+		if !framed {
+			s.readLegacy(ctx, sessionId, conn, bytes)
+			continue
+		}
+		var env envelope
+		if err := json.Unmarshal(bytes, &env); err != nil || env.Type == "" {
+			s.reject(sessionId, conn, env, errCodeInvalidMessage, "expected an envelope with a type")
+			continue
+		}
+		if env.Version != protocolVersion {
+			s.reject(
+				sessionId,
+				conn,
+				env,
+				errCodeUnsupportedVersion,
+				fmt.Sprintf("only version %d is supported", protocolVersion),
+			)
+			continue
+		}
+		if env.Type == commandUserChange {
+			var v userChange
+			if err := json.Unmarshal(env.Payload, &v); err != nil || v.UserId == 0 {
+				s.reject(sessionId, conn, env, errCodeInvalidMessage, "payload needs a user_id")
+				continue
+			}
 			s.logger.Debug("User change", "sessionId", sessionId, "userId", v.UserId)
 			s.sendUserChange(ctx, sessionId, v.UserId)
+			continue
+		}
+		if err := s.runCommand(ctx, sessionId, conn, env.Type); err != nil {
+			s.commandFailed(sessionId, conn, env, err)
 		}
 	}
 }
 
-func (s *Server) clientUpdate() {
-	for {
-		time.Sleep(time.Second)
-		func() {
-			s.sessions.Lock()
-			defer s.sessions.Unlock()
-			for k, v := range s.sessions.room {
-				ctx := context.Background()
-				ctx = context.WithValue(ctx, "sessionId", k)
-				sub, err := s.userService.GetAllUserSubmissionsForSession(ctx, k)
-				if err != nil {
-					s.logger.Error("Failed to get user submissions", "error", err, "sessionId", k)
-					continue
-				}
-				for conn := range v {
-					if err := wsjson.Write(ctx, conn, sub); err != nil {
-						s.logger.Error("Failed to write message", "error", err)
-						continue
-					}
-				}
-				if len(v) == 0 {
-					delete(s.sessions.room, k)
-				}
-			}
-		}()
+// Handles a message from a client that did not negotiate envelopes
+func (s *Server) readLegacy(
+	ctx context.Context,
+	sessionId uint64,
+	conn *websocket.Conn,
+	bytes []byte,
+) {
+	var v clientMessage
+	if err := json.Unmarshal(bytes, &v); err != nil {
+		return
+	}
+	if v.Command != "" {
+		if err := s.runCommand(ctx, sessionId, conn, v.Command); err != nil {
+			s.commandFailed(sessionId, conn, envelope{Type: v.Command}, err)
+		}
+		return
 	}
+	s.logger.Debug("User change", "sessionId", sessionId, "userId", v.UserId)
+	s.sendUserChange(ctx, sessionId, v.UserId)
+}
+
+func (s *Server) commandFailed(sessionId uint64, conn *websocket.Conn, env envelope, err error) {
+	switch {
+	case errors.Is(err, errUnknownCommand):
+		s.reject(sessionId, conn, env, errCodeUnknownCommand, err.Error())
+	case errors.Is(err, errNotFacilitator),
+		errors.Is(err, errCannotParticipate),
+		errors.Is(err, errReadOnlyToken),
+		errors.Is(err, errNotMember):
+		s.reject(sessionId, conn, env, errCodeForbidden, err.Error())
+	default:
+		s.logger.Error("Failed to run command", "error", err, "command", env.Type)
+		s.reject(sessionId, conn, env, errCodeInternal, "internal server error")
+	}
+}
+
+var (
+	errUnknownCommand    = errors.New("unknown command")
+	errNotFacilitator    = errors.New("only the facilitator can do that")
+	errCannotParticipate = errors.New("viewers cannot raise their hand")
+	errReadOnlyToken     = errors.New("token is not allowed to change sessions")
+	errNotMember         = errors.New("no longer a member of the group")
+)
+
+// Connections only need sessions:read, but changing the meeting is a write
+func (c client) canWrite() bool {
+	return Principal{Scopes: c.scopes}.HasScope(user.ScopeSessionsWrite)
 }
 
-func (s *Server) addToSessionMap(sessionId uint64, conn *websocket.Conn) {
+// Applies a meeting command from conn and broadcasts the new state. Moving
+// through the queue and the timer are for the facilitator; anyone taking part
+// can raise a hand.
+func (s *Server) runCommand(
+	ctx context.Context,
+	sessionId uint64,
+	conn *websocket.Conn,
+	command string,
+) error {
+	s.sessions.Lock()
+	c, ok := s.sessions.room[sessionId][conn]
+	s.sessions.Unlock()
+	if !ok {
+		return nil
+	}
+	if !c.canWrite() {
+		return errReadOnlyToken
+	}
+	var apply func(m *meeting, now time.Time)
+	switch command {
+	case commandNext, commandPrev, commandSkip,
+		commandTimerStart, commandTimerPause, commandTimerReset:
+		sess, err := s.sessionService.GetSession(ctx, sessionId)
+		if err != nil {
+			return err
+		}
+		role, err := s.currentRole(ctx, c)
+		if err != nil {
+			return err
+		}
+		if !canFacilitate(sess, c.email, role) {
+			return errNotFacilitator
+		}
+		switch command {
+		case commandNext:
+			apply = (*meeting).next
+		case commandPrev:
+			apply = (*meeting).prev
+		case commandSkip:
+			apply = (*meeting).skip
+		case commandTimerStart:
+			apply = func(m *meeting, now time.Time) { m.timer.start(now) }
+		case commandTimerPause:
+			apply = func(m *meeting, now time.Time) { m.timer.pause(now) }
+		case commandTimerReset:
+			apply = func(m *meeting, now time.Time) { m.timer.reset() }
+		}
+	case commandRaiseHand, commandLowerHand:
+		role, err := s.currentRole(ctx, c)
+		if err != nil {
+			return err
+		}
+		if !role.Can(group.PermSubmit) {
+			return errCannotParticipate
+		}
+		apply = func(m *meeting, now time.Time) { m.raiseHand(c.userID) }
+		if command == commandLowerHand {
+			apply = func(m *meeting, now time.Time) { m.lowerHand(c.userID) }
+		}
+	default:
+		return errUnknownCommand
+	}
+	s.sessions.Lock()
+	m, ok := s.sessions.meetings[sessionId]
+	if !ok {
+		s.sessions.Unlock()
+		return nil
+	}
+	now := time.Now()
+	apply(m, now)
+	s.watchTimer(sessionId, m, now)
+	state := m.state(now)
+	s.sessions.Unlock()
+	s.publish(ctx, sessionId, eventMeeting, state)
+	return nil
+}
+
+// The client's role as it is now rather than when it connected, since it may
+// have been changed since
+func (s *Server) currentRole(ctx context.Context, c client) (group.Role, error) {
+	role, err := s.groupService.GetMemberRole(ctx, c.groupID, c.email)
+	if errors.Is(err, group.ErrNotMember) {
+		return "", errNotMember
+	}
+	return role, err
+}
+
+// Tells the room when the running timer passes its deadline. Must be called
+// with s.sessions locked; any later change to the timer cancels the check.
+// Every instance holding the meeting watches its copy and tells only its own
+// connections, so the room still hears once.
+func (s *Server) watchTimer(sessionId uint64, m *meeting, now time.Time) {
+	if !m.timer.running || m.timer.overtime || m.timer.watched == m.timer.generation {
+		return
+	}
+	generation := m.timer.generation
+	m.timer.watched = generation
+	time.AfterFunc(m.timer.deadline.Sub(now), func() {
+		s.sessions.Lock()
+		if s.sessions.meetings[sessionId] != m || m.timer.generation != generation {
+			s.sessions.Unlock()
+			return
+		}
+		m.timer.overtime = true
+		event := overtimeEvent{Deadline: m.timer.deadline}
+		if id, ok := m.speaker(); ok {
+			event.Speaker = &id
+		}
+		state := m.state(time.Now())
+		// Separate passes, since a slow client dropped by the first is gone by the second
+		for conn, c := range s.sessions.room[sessionId] {
+			s.enqueue(sessionId, conn, c, outgoing{Type: typeMeetingState, Payload: state})
+		}
+		for conn, c := range s.sessions.room[sessionId] {
+			s.enqueue(sessionId, conn, c, outgoing{Type: typeOvertime, Payload: event})
+		}
+		s.sessions.Unlock()
+	})
+}
+
+func (s *Server) addToSessionMap(sessionId uint64, conn *websocket.Conn, c client) {
 	s.sessions.Lock()
 	defer s.sessions.Unlock()
 	if room, ok := s.sessions.room[sessionId]; ok {
-		room[conn] = struct{}{}
+		room[conn] = c
 	} else {
-		room := make(map[*websocket.Conn]struct{})
-		room[conn] = struct{}{}
+		room := make(map[*websocket.Conn]client)
+		room[conn] = c
 		s.sessions.room[sessionId] = room
 	}
 }
 
+// Closes every live connection the user has open to the group's sessions on
+// any instance
+func (s *Server) disconnectFromGroup(ctx context.Context, groupID uint64, email string) {
+	s.publish(ctx, 0, eventDisconnect, groupMember{GroupID: groupID, Email: email})
+}
+
+func (s *Server) closeGroupConns(groupID uint64, email string) {
+	s.sessions.Lock()
+	defer s.sessions.Unlock()
+	for _, room := range s.sessions.room {
+		for conn, c := range room {
+			if c.groupID == groupID && c.email == email {
+				// Closing waits for the peer, so don't hold the lock for it
+				go conn.Close(websocket.StatusPolicyViolation, "removed from group")
+			}
+		}
+	}
+}
+
 func (s *Server) removeFromSessionMap(sessionId uint64, conn *websocket.Conn) {
 	s.sessions.Lock()
 	defer s.sessions.Unlock()
+	s.dropConn(sessionId, conn)
+}
+
+// Removes conn from the room and stops its writer. The meeting ends with the
+// last connection to the session. Must be called with s.sessions locked.
+func (s *Server) dropConn(sessionId uint64, conn *websocket.Conn) {
+	c, ok := s.sessions.room[sessionId][conn]
+	if !ok {
+		return
+	}
+	close(c.send)
 	delete(s.sessions.room[sessionId], conn)
+	if len(s.sessions.room[sessionId]) == 0 {
+		delete(s.sessions.room, sessionId)
+		delete(s.sessions.meetings, sessionId)
+	}
 }
@@ -0,0 +1 @@
+ALTER TABLE user_submissions DROP COLUMN edited_by;
@@ -0,0 +1 @@
+ALTER TABLE user_submissions ADD COLUMN edited_by BIGINT REFERENCES users(id) ON DELETE SET NULL;
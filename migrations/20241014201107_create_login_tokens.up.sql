@@ -0,0 +1,7 @@
+CREATE TABLE login_tokens (
+  id BIGSERIAL PRIMARY KEY,
+  jti TEXT UNIQUE NOT NULL,
+  email TEXT NOT NULL,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  used_at TIMESTAMP WITH TIME ZONE
+);
@@ -0,0 +1,13 @@
+CREATE TABLE group_member_roles (
+  group_id BIGINT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+  email TEXT NOT NULL,
+  role TEXT NOT NULL CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+  PRIMARY KEY (group_id, email)
+);
+
+-- Members without a row are plain members. The first listed email of every
+-- existing group becomes its owner so each group can still be managed.
+INSERT INTO group_member_roles (group_id, email, role)
+SELECT id, allowed_emails[1], 'owner'
+FROM groups
+WHERE cardinality(allowed_emails) > 0;
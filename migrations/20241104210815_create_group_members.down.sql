@@ -0,0 +1,19 @@
+ALTER TABLE groups ADD COLUMN allowed_emails TEXT[];
+
+UPDATE groups g SET allowed_emails = ARRAY(
+  SELECT m.email FROM group_members m
+  WHERE m.group_id = g.id
+  ORDER BY m.position, m.join_date
+);
+
+CREATE TABLE group_member_roles (
+  group_id BIGINT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+  email TEXT NOT NULL,
+  role TEXT NOT NULL CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+  PRIMARY KEY (group_id, email)
+);
+
+INSERT INTO group_member_roles (group_id, email, role)
+SELECT group_id, email, role FROM group_members WHERE role <> 'member';
+
+DROP TABLE group_members;
@@ -0,0 +1,32 @@
+CREATE TABLE group_members (
+  group_id BIGINT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+  email TEXT NOT NULL CHECK (email = lower(email)),
+  role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+  join_date TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+  position INTEGER NOT NULL DEFAULT 0,
+  PRIMARY KEY (group_id, email)
+);
+
+CREATE INDEX group_members_email_idx ON group_members(email);
+
+-- Emails differing only in case collapse into one member, keeping the first
+-- position and the strongest role recorded for any of them.
+INSERT INTO group_members (group_id, email, role, position)
+SELECT
+  g.id,
+  lower(e.email),
+  COALESCE(
+    (SELECT r.role FROM group_member_roles r
+     WHERE r.group_id = g.id AND lower(r.email) = lower(e.email)
+     ORDER BY array_position(ARRAY['owner', 'admin', 'member', 'viewer'], r.role)
+     LIMIT 1),
+    'member'
+  ),
+  MIN(e.ord) - 1
+FROM groups g
+CROSS JOIN LATERAL unnest(g.allowed_emails) WITH ORDINALITY AS e(email, ord)
+WHERE e.email IS NOT NULL AND e.email <> ''
+GROUP BY g.id, lower(e.email);
+
+DROP TABLE group_member_roles;
+ALTER TABLE groups DROP COLUMN allowed_emails;
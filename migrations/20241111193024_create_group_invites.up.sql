@@ -0,0 +1,18 @@
+CREATE TABLE group_invites (
+  id BIGSERIAL PRIMARY KEY,
+  group_id BIGINT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+  -- NULL for shareable links that anyone with the link can use
+  email TEXT CHECK (email = lower(email)),
+  role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member', 'viewer')),
+  token_hash TEXT UNIQUE NOT NULL,
+  created_by TEXT NOT NULL,
+  create_date TIMESTAMP WITH TIME ZONE NOT NULL,
+  expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+  max_uses INTEGER CHECK (max_uses > 0),
+  uses INTEGER NOT NULL DEFAULT 0,
+  revoked_at TIMESTAMP WITH TIME ZONE,
+  declined_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX group_invites_group_id_idx ON group_invites(group_id);
+CREATE INDEX group_invites_email_idx ON group_invites(email) WHERE email IS NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE groups
+  DROP COLUMN description,
+  DROP COLUMN avatar_url;
@@ -0,0 +1,3 @@
+ALTER TABLE groups
+  ADD COLUMN description TEXT NOT NULL DEFAULT '',
+  ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';
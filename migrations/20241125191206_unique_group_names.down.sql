@@ -0,0 +1,3 @@
+ALTER TABLE groups
+  DROP CONSTRAINT groups_name_key,
+  ALTER COLUMN name DROP NOT NULL;
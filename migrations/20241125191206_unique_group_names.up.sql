@@ -0,0 +1,9 @@
+UPDATE groups SET name = 'Group ' || id WHERE name IS NULL OR btrim(name) = '';
+
+-- Older duplicates keep their name, later ones get their id appended
+UPDATE groups g SET name = g.name || ' (' || g.id || ')'
+WHERE EXISTS (SELECT 1 FROM groups o WHERE o.name = g.name AND o.id < g.id);
+
+ALTER TABLE groups
+  ALTER COLUMN name SET NOT NULL,
+  ADD CONSTRAINT groups_name_key UNIQUE (name);
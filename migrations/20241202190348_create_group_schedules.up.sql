@@ -0,0 +1,10 @@
+CREATE TABLE group_schedules (
+  group_id BIGINT PRIMARY KEY REFERENCES groups(id) ON DELETE CASCADE,
+  -- 0 is Sunday, matching Go's time.Weekday
+  weekdays SMALLINT[] NOT NULL CHECK (weekdays <@ ARRAY[0, 1, 2, 3, 4, 5, 6]::SMALLINT[]),
+  -- Wall clock time in the group's timezone
+  local_time TEXT NOT NULL CHECK (local_time ~ '^([01][0-9]|2[0-3]):[0-5][0-9]$'),
+  enabled BOOLEAN NOT NULL DEFAULT true,
+  -- Group local date the scheduler last opened a session for
+  last_run_date DATE
+);
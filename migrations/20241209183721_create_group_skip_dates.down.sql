@@ -0,0 +1,2 @@
+DROP FUNCTION is_skip_date(BIGINT, DATE);
+DROP TABLE group_skip_dates;
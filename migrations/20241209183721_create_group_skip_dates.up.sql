@@ -0,0 +1,24 @@
+CREATE TABLE group_skip_dates (
+  id BIGSERIAL PRIMARY KEY,
+  group_id BIGINT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+  date DATE NOT NULL,
+  name TEXT NOT NULL DEFAULT '',
+  -- weekly skips the date's weekday and yearly its month and day, from date on
+  recurrence TEXT NOT NULL DEFAULT 'once' CHECK (recurrence IN ('once', 'weekly', 'yearly')),
+  UNIQUE (group_id, date, recurrence)
+);
+
+CREATE FUNCTION is_skip_date(gid BIGINT, day DATE) RETURNS BOOLEAN AS $$
+  SELECT EXISTS (
+    SELECT 1 FROM group_skip_dates d
+    WHERE d.group_id = gid
+    AND (
+      d.date = day
+      OR (d.recurrence = 'weekly' AND d.date <= day
+        AND EXTRACT(DOW FROM d.date) = EXTRACT(DOW FROM day))
+      OR (d.recurrence = 'yearly' AND d.date <= day
+        AND EXTRACT(MONTH FROM d.date) = EXTRACT(MONTH FROM day)
+        AND EXTRACT(DAY FROM d.date) = EXTRACT(DAY FROM day))
+    )
+  )
+$$ LANGUAGE SQL STABLE;
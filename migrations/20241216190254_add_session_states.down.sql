@@ -0,0 +1,6 @@
+ALTER TABLE sessions
+  DROP COLUMN state,
+  DROP COLUMN started_at,
+  DROP COLUMN closed_at,
+  DROP COLUMN reopened_at,
+  DROP COLUMN locked_at;
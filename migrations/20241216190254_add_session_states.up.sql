@@ -0,0 +1,14 @@
+ALTER TABLE sessions
+  ADD COLUMN state TEXT NOT NULL DEFAULT 'open'
+    CHECK (state IN ('open', 'in_progress', 'closed', 'locked')),
+  ADD COLUMN started_at TIMESTAMP WITH TIME ZONE,
+  ADD COLUMN closed_at TIMESTAMP WITH TIME ZONE,
+  ADD COLUMN reopened_at TIMESTAMP WITH TIME ZONE,
+  ADD COLUMN locked_at TIMESTAMP WITH TIME ZONE;
+
+-- Only each group's latest session is left open
+UPDATE sessions s SET state = 'closed', closed_at = NOW()
+WHERE EXISTS (
+  SELECT 1 FROM sessions later
+  WHERE later.group_id = s.group_id AND later.create_date > s.create_date
+);
@@ -0,0 +1,5 @@
+ALTER TABLE sessions DROP COLUMN facilitator_email;
+
+ALTER TABLE groups
+  DROP COLUMN facilitator_policy,
+  DROP COLUMN facilitator_email;
@@ -0,0 +1,7 @@
+ALTER TABLE groups
+  ADD COLUMN facilitator_policy TEXT NOT NULL DEFAULT 'round_robin'
+    CHECK (facilitator_policy IN ('round_robin', 'random', 'fixed')),
+  -- Used by the fixed policy
+  ADD COLUMN facilitator_email TEXT CHECK (facilitator_email = lower(facilitator_email));
+
+ALTER TABLE sessions ADD COLUMN facilitator_email TEXT;
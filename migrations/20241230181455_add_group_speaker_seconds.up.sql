@@ -0,0 +1,3 @@
+ALTER TABLE groups
+  ADD COLUMN speaker_seconds INTEGER NOT NULL DEFAULT 120
+    CHECK (speaker_seconds BETWEEN 10 AND 3600);
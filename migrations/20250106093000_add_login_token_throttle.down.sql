@@ -0,0 +1,3 @@
+DROP INDEX login_tokens_ip_created_at;
+DROP INDEX login_tokens_email_created_at;
+ALTER TABLE login_tokens DROP COLUMN created_at, DROP COLUMN ip;
@@ -0,0 +1,5 @@
+ALTER TABLE login_tokens
+  ADD COLUMN ip TEXT NOT NULL DEFAULT '',
+  ADD COLUMN created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW();
+CREATE INDEX login_tokens_email_created_at ON login_tokens (email, created_at);
+CREATE INDEX login_tokens_ip_created_at ON login_tokens (ip, created_at);